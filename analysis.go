@@ -0,0 +1,468 @@
+package gochess
+
+// PawnInfo describes the pawn structure of a side.
+// The fields hold the squares of the pawns like e4
+type PawnInfo struct {
+	// Doubled are the pawns that share their file with other friendly pawns
+	Doubled []string
+	// Isolated are the pawns without friendly pawns on the adjacent files
+	Isolated []string
+	// Passed are the pawns without enemy pawns in front of them
+	// on their file or the adjacent files. Of doubled pawns only
+	// the front one can be passed
+	Passed []string
+}
+
+func playSquare(r, f int) string {
+	return string([]byte{"abcdefgh"[f], byte('8' - r)})
+}
+
+// pawnFiles counts the pawns of col on each file
+func (b *Board) pawnFiles(col color) [8]int {
+	var files [8]int
+	pawn := newPiece(col, pPAWN, false)
+	for _, rank := range b.play {
+		for f, p := range rank {
+			if p&^0x08 == pawn {
+				files[f]++
+			}
+		}
+	}
+	return files
+}
+
+// OpenFiles returns the files, like e, that have no pawns at all
+func (b *Board) OpenFiles() []string {
+	white, black := b.pawnFiles(cWHITE), b.pawnFiles(cBLACK)
+	s := make([]string, 0)
+	for f := range white {
+		if white[f] == 0 && black[f] == 0 {
+			s = append(s, "abcdefgh"[f:f+1])
+		}
+	}
+	return s
+}
+
+// HalfOpenFiles returns the files that have no pawns of a side
+// but have pawns of the opponent. Open files are not included
+func (b *Board) HalfOpenFiles(white bool) []string {
+	col := colorOf(white)
+	own, enemy := b.pawnFiles(col), b.pawnFiles(col.opposite())
+	s := make([]string, 0)
+	for f := range own {
+		if own[f] == 0 && enemy[f] != 0 {
+			s = append(s, "abcdefgh"[f:f+1])
+		}
+	}
+	return s
+}
+
+// PawnStructure returns the doubled, isolated and passed pawns of a side
+func (b *Board) PawnStructure(white bool) PawnInfo {
+	col := colorOf(white)
+	pawn, enemy := newPiece(col, pPAWN, false), newPiece(col.opposite(), pPAWN, false)
+	files := b.pawnFiles(col)
+	var info PawnInfo
+	for r, rank := range b.play {
+		for f, p := range rank {
+			if p&^0x08 != pawn {
+				continue
+			}
+			sq := playSquare(r, f)
+			if files[f] > 1 {
+				info.Doubled = append(info.Doubled, sq)
+			}
+			if (f == 0 || files[f-1] == 0) && (f == 7 || files[f+1] == 0) {
+				info.Isolated = append(info.Isolated, sq)
+			}
+			passed, dr := true, -1
+			if !white {
+				dr = 1
+			}
+			for ar := r + dr; ar >= 0 && ar < 8 && passed; ar += dr {
+				if b.play[ar][f]&^0x08 == pawn {
+					passed = false
+				}
+				for af := f - 1; af <= f+1; af++ {
+					if af >= 0 && af < 8 && b.play[ar][af]&^0x08 == enemy {
+						passed = false
+					}
+				}
+			}
+			if passed {
+				info.Passed = append(info.Passed, sq)
+			}
+		}
+	}
+	return info
+}
+
+// PieceValues are the values of the piece types in centipawns, indexed
+// by type: pawn 1, knight 2, bishop 3, rook 4, queen 5 and king 6
+type PieceValues [7]int
+
+var (
+	// DefaultPieceValues are the piece values a board uses unless
+	// its Values field is set
+	DefaultPieceValues PieceValues = PieceValues{0, 100, 300, 300, 500, 900, 0}
+)
+
+// values returns the piece values of the board
+func (b *Board) values() *PieceValues {
+	if b.Values != nil {
+		return b.Values
+	}
+	return &DefaultPieceValues
+}
+
+// MaterialBalance returns the material of white minus the material
+// of black in centipawns, with the piece values of the board
+func (b *Board) MaterialBalance() int {
+	balance := 0
+	for _, rank := range b.play {
+		for _, p := range rank {
+			if col, typ := p.identify(); p != 0 && col == cWHITE {
+				balance += b.values()[typ]
+			} else if p != 0 {
+				balance -= b.values()[typ]
+			}
+		}
+	}
+	return balance
+}
+
+// HangingPieces returns the squares of the pieces of a side that the
+// opponent attacks and are either not defended at all or attacked by
+// a piece of lower value, so that capturing them wins material
+func (b *Board) HangingPieces(white bool) []string {
+	col := colorOf(white)
+	s := make([]string, 0)
+	for sq := int8(21); sq < 99; sq++ {
+		p := b.sq[sq]
+		if p == 0 || p == 0xff {
+			continue
+		}
+		c, typ := p.identify()
+		if c != col || typ == pKING {
+			continue
+		}
+		attackers := b.attackersOf(sq, col.opposite())
+		if len(attackers) == 0 {
+			continue
+		}
+		hanging := len(b.attackersOf(sq, col)) == 0
+		for _, a := range attackers {
+			if _, t := b.sq[a].identify(); t != pKING && b.values()[t] < b.values()[typ] {
+				hanging = true
+			}
+		}
+		if hanging {
+			s = append(s, sq2string(sq))
+		}
+	}
+	return s
+}
+
+// exchangeValue is the value of a piece type in exchanges,
+// where losing the king outweighs everything else
+func (b *Board) exchangeValue(typ uint8) int {
+	if typ == pKING {
+		return 20000
+	}
+	return b.values()[typ]
+}
+
+// leastValuableAttacker returns the least valuable piece of col attacking sq
+func (b *Board) leastValuableAttacker(sq int8, col color) (int8, bool) {
+	best, found := int8(0), false
+	for _, a := range b.attackersOf(sq, col) {
+		_, t := b.sq[a].identify()
+		if _, bt := b.sq[best].identify(); !found || b.exchangeValue(t) < b.exchangeValue(bt) {
+			best, found = a, true
+		}
+	}
+	return best, found
+}
+
+// StaticExchange returns the material gain in centipawns for a side if it
+// starts capturing on square and both sides keep recapturing with their least
+// valuable attacker for as long as it pays off. Either side may stop capturing.
+// The result is 0 if square does not hold an opponent's piece and it is
+// negative if the first capture loses material. Pins are not considered
+func (b *Board) StaticExchange(square string, white bool) int {
+	sq, err := parseSquare(square)
+	if err != nil || b.sq[sq] == 0 {
+		return 0
+	}
+	col := colorOf(white)
+	c, typ := b.sq[sq].identify()
+	if c == col {
+		return 0
+	}
+	board := b.clone()
+	gains := make([]int, 0, 8)
+	captured := b.exchangeValue(typ)
+	for side := col; ; side = side.opposite() {
+		from, ok := board.leastValuableAttacker(sq, side)
+		if !ok {
+			break
+		}
+		if len(gains) == 0 {
+			gains = append(gains, captured)
+		} else {
+			gains = append(gains, captured-gains[len(gains)-1])
+		}
+		_, t := board.sq[from].identify()
+		captured = b.exchangeValue(t)
+		board.sq[sq], board.sq[from] = board.sq[from], 0
+	}
+	if len(gains) == 0 {
+		return 0
+	}
+	for d := len(gains) - 1; d > 0; d-- {
+		if gains[d] > -gains[d-1] {
+			gains[d-1] = -gains[d]
+		}
+	}
+	return gains[0]
+}
+
+// DevelopmentScore returns a small metric of the opening development of
+// a side. It counts one point for each knight and bishop that has left its
+// home square, one for each of the d and e pawns that has advanced and
+// two if the king has castled. It ranges from 0 to 8
+func (b *Board) DevelopmentScore(white bool) int {
+	col := colorOf(white)
+	home, pawns := int8(21), int8(31)
+	if !white {
+		home, pawns = 91, 81
+	}
+	score := 0
+	minors := [8]uint8{0, pKNIGHT, pBISHOP, 0, 0, pBISHOP, pKNIGHT, 0}
+	for f, typ := range minors {
+		if typ != 0 && b.sq[home+int8(f)] != newPiece(col, typ, false) {
+			score++
+		}
+	}
+	for _, f := range []int8{3, 4} {
+		if b.sq[pawns+f] != newPiece(col, pPAWN, false) {
+			score++
+		}
+	}
+	king, rook := newPiece(col, pKING, true), newPiece(col, pROOK, true)
+	if (b.sq[home+6] == king && b.sq[home+5] == rook) || (b.sq[home+2] == king && b.sq[home+3] == rook) {
+		score += 2
+	}
+	return score
+}
+
+var (
+	// pieceSquareTables are bonuses in centipawns for the placement of the
+	// piece types, for white with a8 first as the board is printed.
+	// For black the tables are mirrored vertically
+	pieceSquareTables [7][64]int = [7][64]int{
+		{},
+		{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			50, 50, 50, 50, 50, 50, 50, 50,
+			10, 10, 20, 30, 30, 20, 10, 10,
+			5, 5, 10, 25, 25, 10, 5, 5,
+			0, 0, 0, 20, 20, 0, 0, 0,
+			5, -5, -10, 0, 0, -10, -5, 5,
+			5, 10, 10, -20, -20, 10, 10, 5,
+			0, 0, 0, 0, 0, 0, 0, 0,
+		},
+		{
+			-50, -40, -30, -30, -30, -30, -40, -50,
+			-40, -20, 0, 0, 0, 0, -20, -40,
+			-30, 0, 10, 15, 15, 10, 0, -30,
+			-30, 5, 15, 20, 20, 15, 5, -30,
+			-30, 0, 15, 20, 20, 15, 0, -30,
+			-30, 5, 10, 15, 15, 10, 5, -30,
+			-40, -20, 0, 5, 5, 0, -20, -40,
+			-50, -40, -30, -30, -30, -30, -40, -50,
+		},
+		{
+			-20, -10, -10, -10, -10, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 10, 10, 5, 0, -10,
+			-10, 5, 5, 10, 10, 5, 5, -10,
+			-10, 0, 10, 10, 10, 10, 0, -10,
+			-10, 10, 10, 10, 10, 10, 10, -10,
+			-10, 5, 0, 0, 0, 0, 5, -10,
+			-20, -10, -10, -10, -10, -10, -10, -20,
+		},
+		{
+			0, 0, 0, 0, 0, 0, 0, 0,
+			5, 10, 10, 10, 10, 10, 10, 5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			-5, 0, 0, 0, 0, 0, 0, -5,
+			0, 0, 0, 5, 5, 0, 0, 0,
+		},
+		{
+			-20, -10, -10, -5, -5, -10, -10, -20,
+			-10, 0, 0, 0, 0, 0, 0, -10,
+			-10, 0, 5, 5, 5, 5, 0, -10,
+			-5, 0, 5, 5, 5, 5, 0, -5,
+			0, 0, 5, 5, 5, 5, 0, -5,
+			-10, 5, 5, 5, 5, 5, 0, -10,
+			-10, 0, 5, 0, 0, 0, 0, -10,
+			-20, -10, -10, -5, -5, -10, -10, -20,
+		},
+		{
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-20, -30, -30, -40, -40, -30, -30, -20,
+			-10, -20, -20, -20, -20, -20, -20, -10,
+			20, 20, 0, 0, 0, 0, 20, 20,
+			20, 30, 10, 0, 0, 10, 30, 20,
+		},
+	}
+)
+
+// Evaluate returns a static score of the position in centipawns from
+// white's side. It adds the material and the piece-square table bonuses
+// of white and subtracts those of black. It does not search, so
+// pieces about to be captured still count
+func (b *Board) Evaluate() int {
+	score := 0
+	for r, rank := range b.play {
+		for f, p := range rank {
+			if p == 0 {
+				continue
+			}
+			col, typ := p.identify()
+			if col == cWHITE {
+				score += b.values()[typ] + pieceSquareTables[typ][r*8+f]
+			} else {
+				score -= b.values()[typ] + pieceSquareTables[typ][(7-r)*8+f]
+			}
+		}
+	}
+	return score
+}
+
+// IsQuiet reports whether the side to move is not in check and has no
+// capture that wins material by StaticExchange. Engines stop their
+// quiescence search at such positions
+func (b *Board) IsQuiet() bool {
+	if b.inCheck() {
+		return false
+	}
+	for _, m := range b.legalMoves() {
+		if b.sq[m.to] != 0 && b.StaticExchange(sq2string(m.to), b.activeMove == cWHITE) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// quiescentScore returns a score of the position in centipawns for the
+// side to move: the static evaluation plus the gain of its best capture,
+// so that pieces left en prise count as lost
+func (b *Board) quiescentScore() int {
+	score := b.Evaluate()
+	if b.activeMove == cBLACK {
+		score = -score
+	}
+	best := 0
+	for _, m := range b.legalMoves() {
+		if b.sq[m.to] != 0 {
+			if gain := b.StaticExchange(sq2string(m.to), b.activeMove == cWHITE); gain > best {
+				best = gain
+			}
+		}
+	}
+	return score + best
+}
+
+// Threats returns in SAN the moves of the opponent that would win
+// material or checkmate if it were its turn to move: checkmates,
+// captures that gain material by StaticExchange and forks, moves to
+// a safe square after which two pieces, or the king in check and
+// another piece, are attacked and cannot all be saved
+func (b *Board) Threats() []string {
+	s := make([]string, 0)
+	if b.inCheck() {
+		// the opponent could capture the king
+		return s
+	}
+	us := b.activeMove
+	them := b.clone()
+	them.activeMove, them.epsq, them.status = us.opposite(), 0, ""
+	hanging := make(map[string]bool)
+	for _, sq := range them.HangingPieces(us == cWHITE) {
+		hanging[sq] = true
+	}
+	for _, m := range them.legalMoves() {
+		after := them.clone()
+		after.makeMove(m, "")
+		threat := after.Status() == "checkmate"
+		if !threat && b.sq[m.to] != 0 {
+			threat = them.StaticExchange(sq2string(m.to), us != cWHITE) > 0
+		} else if !threat && after.StaticExchange(sq2string(m.to), us == cWHITE) <= 0 {
+			attacked := 0
+			for _, sq := range after.HangingPieces(us == cWHITE) {
+				if !hanging[sq] {
+					attacked++
+				}
+			}
+			threat = attacked >= 2 || (attacked == 1 && after.inCheck())
+		}
+		if threat {
+			s = append(s, them.san(m))
+		}
+	}
+	return s
+}
+
+// CanCheckmate reports whether a side has enough material to checkmate
+// at all, as needed to rule when a player runs out of time. A lone king
+// or a king with a single knight or bishop cannot. It says nothing about
+// the material of the opponent
+func (b *Board) CanCheckmate(white bool) bool {
+	col := colorOf(white)
+	minors := 0
+	for _, rank := range b.play {
+		for _, p := range rank {
+			c, typ := p.identify()
+			if p == 0 || c != col {
+				continue
+			}
+			switch typ {
+			case pPAWN, pROOK, pQUEEN:
+				return true
+			case pKNIGHT, pBISHOP:
+				minors++
+			}
+		}
+	}
+	return minors > 1
+}
+
+// MaterialSignature returns the material of the position as in the names
+// of endgame tables, like KRvKN: the pieces of white, v and the pieces of
+// black, each starting with the king and going down in value
+func (b *Board) MaterialSignature() string {
+	return b.material(cWHITE) + "v" + b.material(cBLACK)
+}
+
+// material returns the pieces of a side as in endgame table names, like KRP
+func (b *Board) material(col color) string {
+	s := ""
+	for _, typ := range []uint8{pKING, pQUEEN, pROOK, pBISHOP, pKNIGHT, pPAWN} {
+		for _, rank := range b.play {
+			for _, p := range rank {
+				if c, t := p.identify(); p != 0 && c == col && t == typ {
+					s += string("PNBRQK"[typ-1])
+				}
+			}
+		}
+	}
+	return s
+}
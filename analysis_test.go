@@ -0,0 +1,219 @@
+package gochess
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPieceValuesSurviveUndo(t *testing.T) {
+	b := mustFEN(t, "4k3/8/8/8/3p4/8/4N3/4K3 w - - 0 1")
+	if err := b.MakeMove("Nxd4"); err != nil {
+		t.Fatal(err)
+	}
+	values := DefaultPieceValues
+	values[pKNIGHT] = 325
+	b.Values = &values
+	if err := b.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Values != &values {
+		t.Fatalf("Undo reset the piece values to %v", b.Values)
+	}
+	if balance := b.MaterialBalance(); balance != 225 {
+		t.Errorf("MaterialBalance() = %d, want 225", balance)
+	}
+}
+
+func sameSquares(a, b []string) bool {
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return len(a) == len(b) && (len(a) == 0 || reflect.DeepEqual(a, b))
+}
+
+func TestPawnStructure(t *testing.T) {
+	b := mustFEN(t, "4k3/p5p1/8/1P6/8/2P3P1/2P5/4K3 w - - 0 1")
+	info := b.PawnStructure(true)
+	if !sameSquares(info.Doubled, []string{"c3", "c2"}) {
+		t.Errorf("white doubled pawns = %v, want c3 c2", info.Doubled)
+	}
+	if !sameSquares(info.Isolated, []string{"g3"}) {
+		t.Errorf("white isolated pawns = %v, want g3", info.Isolated)
+	}
+	if !sameSquares(info.Passed, []string{"c3"}) {
+		t.Errorf("white passed pawns = %v, want c3", info.Passed)
+	}
+	info = b.PawnStructure(false)
+	if !sameSquares(info.Isolated, []string{"a7", "g7"}) || !sameSquares(info.Passed, nil) || !sameSquares(info.Doubled, nil) {
+		t.Errorf("black pawn structure = %+v", info)
+	}
+}
+
+func TestHangingPieces(t *testing.T) {
+	b := mustFEN(t, "4k3/8/3r4/8/3B4/2P5/8/4K3 b - - 0 1")
+	if s := b.HangingPieces(true); !sameSquares(s, nil) {
+		t.Errorf("white hanging pieces = %v, want none", s)
+	}
+	b = mustFEN(t, "4k3/8/3r4/8/3B4/8/8/4K3 b - - 0 1")
+	if s := b.HangingPieces(true); !sameSquares(s, []string{"d4"}) {
+		t.Errorf("white hanging pieces = %v, want d4", s)
+	}
+	b = mustFEN(t, "4k3/8/8/2p5/3R4/8/8/3QK3 b - - 0 1")
+	if s := b.HangingPieces(true); !sameSquares(s, []string{"d4"}) {
+		t.Errorf("defended rook attacked by a pawn: hanging = %v, want d4", s)
+	}
+	if s := b.HangingPieces(false); !sameSquares(s, nil) {
+		t.Errorf("black hanging pieces = %v, want none", s)
+	}
+}
+
+func TestStaticExchange(t *testing.T) {
+	tests := []struct {
+		fen, square string
+		white       bool
+		want        int
+	}{
+		{"4k3/8/4p3/3p4/8/8/8/3RK3 w - - 0 1", "d5", true, -400},
+		{"4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1", "d5", true, 100},
+		{"4k3/8/4p3/3n4/8/2N5/8/3RK3 w - - 0 1", "d5", true, 100},
+		{"3rk3/3r4/8/3p4/8/8/3R4/3RK3 w - - 0 1", "d5", true, -400},
+		{"4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1", "d1", false, 0},
+		{"4k3/8/8/3p4/8/8/8/3RK3 w - - 0 1", "e4", true, 0},
+	}
+	for _, tt := range tests {
+		if n := mustFEN(t, tt.fen).StaticExchange(tt.square, tt.white); n != tt.want {
+			t.Errorf("StaticExchange(%s, %v) in %s = %d, want %d", tt.square, tt.white, tt.fen, n, tt.want)
+		}
+	}
+}
+
+func TestDevelopmentScore(t *testing.T) {
+	b := NewBoard()
+	if w, bl := b.DevelopmentScore(true), b.DevelopmentScore(false); w != 0 || bl != 0 {
+		t.Errorf("DevelopmentScore() of the initial position = %d, %d, want 0, 0", w, bl)
+	}
+	b, err := NewBoardFromMoves("e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "O-O", "d6", "d3", "Nf6", "Nc3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := b.DevelopmentScore(true); n != 7 {
+		t.Errorf("DevelopmentScore(true) = %d, want 7", n)
+	}
+	if n := b.DevelopmentScore(false); n != 5 {
+		t.Errorf("DevelopmentScore(false) = %d, want 5", n)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	if n := NewBoard().Evaluate(); n < -50 || n > 50 {
+		t.Errorf("Evaluate() of the initial position = %d, want near 0", n)
+	}
+	if n := mustFEN(t, "rnb1kbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1").Evaluate(); n < 800 {
+		t.Errorf("Evaluate() a queen up = %d, want at least 800", n)
+	}
+	if n := mustFEN(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNB1KBNR w KQkq - 0 1").Evaluate(); n > -800 {
+		t.Errorf("Evaluate() a queen down = %d, want at most -800", n)
+	}
+	b, err := NewBoardFromMoves("e4", "e5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := b.Evaluate(); n != 0 {
+		t.Errorf("Evaluate() of a symmetric position = %d, want 0", n)
+	}
+}
+
+func TestIsQuiet(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5", "d3", "d6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsQuiet() {
+		t.Errorf("IsQuiet() of a calm position is false")
+	}
+	if mustFEN(t, "4k3/8/8/3q4/8/2N5/8/4K3 w - - 0 1").IsQuiet() {
+		t.Errorf("IsQuiet() with a hanging queen is true")
+	}
+	if mustFEN(t, "4k3/8/8/8/8/8/8/R3K3 b - - 0 1").IsQuiet() != true {
+		t.Errorf("IsQuiet() without captures is false")
+	}
+	if mustFEN(t, "R3k3/8/8/8/8/8/8/4K3 b - - 0 1").IsQuiet() {
+		t.Errorf("IsQuiet() in check is true")
+	}
+}
+
+func TestThreats(t *testing.T) {
+	tests := []struct {
+		fen, threat string
+	}{
+		{"r3k3/8/8/8/8/8/8/R3K3 w - - 0 1", "Rxa1+"},
+		{"r5k1/8/8/8/8/8/5PPP/6K1 w - - 0 1", "Ra1#"},
+		{"4k3/8/8/8/1n6/8/8/R3K2Q w - - 0 1", "Nc2+"},
+	}
+	for _, test := range tests {
+		if threats := mustFEN(t, test.fen).Threats(); !contains(threats, test.threat) {
+			t.Errorf("Threats() of %s = %v, want %s", test.fen, threats, test.threat)
+		}
+	}
+	if threats := NewBoard().Threats(); len(threats) != 0 {
+		t.Errorf("Threats() of the initial position = %v", threats)
+	}
+	if threats := mustFEN(t, "4k3/8/8/8/8/8/8/r3K3 w - - 0 1").Threats(); len(threats) != 0 {
+		t.Errorf("Threats() in check = %v", threats)
+	}
+}
+
+func TestCanCheckmate(t *testing.T) {
+	tests := []struct {
+		fen          string
+		white, black bool
+	}{
+		{"4k3/8/8/8/8/8/8/4K3 w - - 0 1", false, false},
+		{"4k3/8/8/8/8/8/8/3NK3 w - - 0 1", false, false},
+		{"4kb2/8/8/8/8/8/8/3NKN2 w - - 0 1", true, false},
+		{"4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1", true, false},
+		{"4k3/4p3/8/8/8/8/8/4K3 w - - 0 1", false, true},
+		{"3qk3/8/8/8/8/8/8/R3K3 w - - 0 1", true, true},
+	}
+	for _, test := range tests {
+		b := mustFEN(t, test.fen)
+		if got := b.CanCheckmate(true); got != test.white {
+			t.Errorf("CanCheckmate(white) of %s = %v, want %v", test.fen, got, test.white)
+		}
+		if got := b.CanCheckmate(false); got != test.black {
+			t.Errorf("CanCheckmate(black) of %s = %v, want %v", test.fen, got, test.black)
+		}
+	}
+}
+
+func TestOpenFiles(t *testing.T) {
+	b := mustFEN(t, "4k3/pp3ppp/8/8/8/8/PP2PPPP/4K3 w - - 0 1")
+	if files := b.OpenFiles(); !reflect.DeepEqual(files, []string{"c", "d"}) {
+		t.Errorf("OpenFiles() = %v, want [c d]", files)
+	}
+	if files := b.HalfOpenFiles(true); len(files) != 0 {
+		t.Errorf("HalfOpenFiles(white) = %v, want none", files)
+	}
+	if files := b.HalfOpenFiles(false); !reflect.DeepEqual(files, []string{"e"}) {
+		t.Errorf("HalfOpenFiles(black) = %v, want [e]", files)
+	}
+	if files := NewBoard().OpenFiles(); len(files) != 0 {
+		t.Errorf("OpenFiles() of the initial position = %v", files)
+	}
+}
+
+func TestMaterialSignature(t *testing.T) {
+	tests := []struct {
+		fen, want string
+	}{
+		{"4k3/8/8/8/8/8/8/4KQ2 w - - 0 1", "KQvK"},
+		{"4k1n1/8/8/8/8/8/8/R3K3 w - - 0 1", "KRvKN"},
+		{"4k3/2p5/8/8/8/8/1P6/1B2K1N1 b - - 0 1", "KBNPvKP"},
+	}
+	for _, test := range tests {
+		if s := mustFEN(t, test.fen).MaterialSignature(); s != test.want {
+			t.Errorf("MaterialSignature() of %s = %q, want %q", test.fen, s, test.want)
+		}
+	}
+}
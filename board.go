@@ -2,6 +2,7 @@ package gochess
 
 import (
 	"fmt"
+	"hash/fnv"
 	"regexp"
 	"strconv"
 	"strings"
@@ -50,6 +51,14 @@ func string2sq(sq string) int8 {
 	return int8(21 + (sq[1]-'1')*10 + sq[0] - 'a')
 }
 
+// parseSquare converts an algebraic square like e4 to a board index
+func parseSquare(sq string) (int8, error) {
+	if len(sq) != 2 || sq[0] < 'a' || sq[0] > 'h' || sq[1] < '1' || sq[1] > '8' {
+		return 0, fmt.Errorf("square %q is not valid", sq)
+	}
+	return string2sq(sq), nil
+}
+
 type color uint8
 
 type piece uint8
@@ -65,8 +74,17 @@ type Board struct {
 	bksq            int8
 	activeMove color
 	lastSAN string
+	lastMove move
+	halfmoves int
+	captured []piece
+	status string
+	// history has the states of the board before each move, for Undo
+	history []Board
 	MoveWhite bool
 	MoveNumber uint8
+	// Values are the piece values for evaluation and exchanges.
+	// DefaultPieceValues are used if it is nil
+	Values *PieceValues
 }
 
 func colorOf(b bool) color {
@@ -111,6 +129,12 @@ func (p piece) markedMoved() piece {
 	return p
 }
 
+// isPromoted reports whether the piece came from a promotion.
+// The bit 0x10 marks promoted pieces
+func (p piece) isPromoted() bool {
+	return p&0x10 != 0
+}
+
 func (p piece) String() string {
 	col, typ := p.identify()
 	if col == cWHITE {
@@ -125,13 +149,24 @@ func NewBoardFromFen(fen string) (*Board, error) {
 	if parts == nil || len(parts) == 0 {
 		return nil, fmt.Errorf("fen is wrong")
 	}
+	if len(parts) < 6 {
+		// missing fields get their usual defaults
+		parts = append(parts, []string{"", "w", "-", "-", "0", "1"}[len(parts):]...)
+	}
 	b := new(Board)
 
 	b.activeMove = colorOf(parts[1] == "w")
-//	if parts[3] != "-" {
-//		b.epsq = string2sq(parts[3])
-//	}
-	// TODO castling
+	b.MoveWhite = parts[1] == "w"
+	if parts[3] != "-" {
+		sq, err := parseSquare(parts[3])
+		if err != nil || (sq/10 != 7 && sq/10 != 4) {
+			return nil, fmt.Errorf("fen is wrong: bad en passant square %q", parts[3])
+		}
+		b.epsq = sq
+	}
+	if n, err := strconv.Atoi(parts[4]); err == nil {
+		b.halfmoves = n
+	}
 	if n, err := strconv.Atoi(parts[5]); err == nil {
 		b.MoveNumber = uint8(n)
 	}
@@ -180,6 +215,18 @@ func NewBoardFromFen(fen string) (*Board, error) {
 			}
 		}
 	}
+	// castling availability. Rooks without rights are marked as moved
+	for i, corner := range []int8{28, 21, 98, 91} {
+		if !strings.ContainsRune(parts[2], rune("KQkq"[i])) && b.sq[corner] != 0 {
+			b.sq[corner] = b.sq[corner].markedMoved()
+		}
+	}
+	b.linkPlay()
+	return b, nil
+}
+
+// linkPlay points the ranks in play to the squares of the board
+func (b *Board) linkPlay() {
 	b.play[0] = b.sq[91:99]
 	b.play[1] = b.sq[81:89]
 	b.play[2] = b.sq[71:79]
@@ -188,7 +235,89 @@ func NewBoardFromFen(fen string) (*Board, error) {
 	b.play[5] = b.sq[41:49]
 	b.play[6] = b.sq[31:39]
 	b.play[7] = b.sq[21:29]
-	return b, nil
+}
+
+// Verify checks the invariants of the board: the border squares
+// are marked, wksq and bksq hold the kings, the ranks of play are
+// the squares of the board and an en passant square is empty with
+// the pawn that made the double step in front of it. It is meant for
+// tests and debugging, to catch corruption after editing the board
+func (b *Board) Verify() error {
+	for sq, p := range b.sq {
+		if (sq < 21 || sq > 98 || sq%10 == 0 || sq%10 == 9) != (p == 0xff) {
+			return fmt.Errorf("square %d: bad border marking %#x", sq, p)
+		}
+	}
+	for col, ksq := range []int8{b.wksq, b.bksq} {
+		if b.sq[ksq] == 0xff {
+			return fmt.Errorf("%s king is missing", boolAsColor(col == cWHITE))
+		}
+		if c, t := b.sq[ksq].identify(); c != color(col) || t != pKING {
+			return fmt.Errorf("%s king is not on %s", boolAsColor(col == cWHITE), sq2string(ksq))
+		}
+	}
+	for r, rank := range b.play {
+		if len(rank) != 8 || &rank[0] != &b.sq[91-10*r] {
+			return fmt.Errorf("rank %d is not linked to the board", 8-r)
+		}
+	}
+	if b.epsq != 0 {
+		pawn, behind := newPiece(cBLACK, pPAWN, true), b.epsq-10
+		if b.activeMove == cBLACK {
+			pawn, behind = newPiece(cWHITE, pPAWN, true), b.epsq+10
+		}
+		if b.epsq/10 != 7 && b.epsq/10 != 4 {
+			return fmt.Errorf("en passant square %s is not on the third or sixth rank", sq2string(b.epsq))
+		}
+		if b.sq[b.epsq] != 0 || b.sq[behind]|0x08 != pawn {
+			return fmt.Errorf("en passant square %s does not follow a double pawn step", sq2string(b.epsq))
+		}
+	}
+	return nil
+}
+
+// Snapshot returns a copy of the squares of the board. It is a cheap way to
+// save the piece placement, and the castling rights that follow from it,
+// for RestoreSnapshot. The side to move, the en passant square and the
+// move counters are not part of it
+func (b *Board) Snapshot() [120]piece {
+	return b.sq
+}
+
+// RestoreSnapshot sets the squares of the board to a snapshot taken
+// with Snapshot. The rest of the board state stays as it is
+func (b *Board) RestoreSnapshot(s [120]piece) {
+	b.sq = s
+	b.linkPlay()
+	b.status = ""
+	for sq, p := range b.sq {
+		if p == newPiece(cWHITE, pKING, false) || p == newPiece(cWHITE, pKING, true) {
+			b.wksq = int8(sq)
+		} else if p == newPiece(cBLACK, pKING, false) || p == newPiece(cBLACK, pKING, true) {
+			b.bksq = int8(sq)
+		}
+	}
+}
+
+// ToArray64 returns the squares of the board in the 0-63 order used by
+// many chess programs: a1, b1, ..., h1, a2, ..., h8
+func (b *Board) ToArray64() [64]piece {
+	var a [64]piece
+	for i := range a {
+		a[i] = b.sq[21+(i/8)*10+i%8]
+	}
+	return a
+}
+
+// clone returns a copy of the board that can be changed independently.
+// The copy is for trying moves so it does not have the history of the
+// board. Undo on it can take back only the moves made on the copy
+func (b *Board) clone() *Board {
+	c := *b
+	c.linkPlay()
+	c.captured = append([]piece(nil), b.captured...)
+	c.history = nil
+	return &c
 }
 
 // NewBoard returns a Board object initialized with the standard starting position
@@ -197,6 +326,44 @@ func NewBoard() *Board {
 	return b
 }
 
+// NewBoardFromArray64 returns a Board with the pieces of squares, in the
+// order of ToArray64: a1, b1, ..., h1, a2, ..., h8. Each element is a piece
+// letter as in FEN or the empty string for an empty square. White is to
+// move and the castling rights are those the king and rook placement allows.
+// Each side must have exactly one king
+func NewBoardFromArray64(squares [64]string) (*Board, error) {
+	kings := map[string]int{}
+	var ranks [8]string
+	for i, p := range squares {
+		switch {
+		case p == "":
+			p = "1"
+		case len(p) != 1 || !strings.Contains("PNBRQKpnbrqk", p):
+			return nil, fmt.Errorf("square %s: %q is not a piece", "abcdefgh"[i%8:i%8+1]+strconv.Itoa(i/8+1), p)
+		case p == "K" || p == "k":
+			kings[p]++
+		}
+		ranks[7-i/8] += p
+	}
+	if kings["K"] != 1 || kings["k"] != 1 {
+		return nil, fmt.Errorf("there are %d white and %d black kings", kings["K"], kings["k"])
+	}
+	return NewBoardFromFen(strings.Join(ranks[:], "/") + " w KQkq - 0 1")
+}
+
+// NewBoardFromMoves returns a Board object initialized with the standard
+// starting position after playing the moves in sans. It returns an error
+// for the first move that cannot be made
+func NewBoardFromMoves(sans ...string) (*Board, error) {
+	b := NewBoard()
+	for i, san := range sans {
+		if err := b.MakeMove(san); err != nil {
+			return nil, fmt.Errorf("move %d: %s", i+1, err)
+		}
+	}
+	return b, nil
+}
+
 func (b *Board) attackersOf(sq int8, col color) []int8 {
 	s := make([]int8, 0)
 	for _, d := range dKNIGHT {
@@ -247,6 +414,107 @@ func (b *Board) attackersOf(sq int8, col color) []int8 {
 	return s
 }
 
+// isAttacked reports whether a piece of col attacks sq. It is
+// len(b.attackersOf(sq, col)) != 0 without building the list
+func (b *Board) isAttacked(sq int8, col color) bool {
+	for _, d := range dKNIGHT {
+		if c, t := b.sq[sq+d].identify(); c == col && t == pKNIGHT {
+			return true
+		}
+	}
+	for _, d := range dKING {
+		if c, t := b.sq[sq+d].identify(); c == col && t == pKING {
+			return true
+		}
+	}
+	for _, d := range dDIAGONAL {
+		for from := sq + d; b.sq[from] != 0xff; from += d {
+			if p := b.sq[from]; p != 0 {
+				if c, t := p.identify(); c == col && (t == pQUEEN || t == pBISHOP) {
+					return true
+				}
+				break
+			}
+		}
+	}
+	for _, d := range dSTRAIGHT {
+		for from := sq + d; b.sq[from] != 0xff; from += d {
+			if p := b.sq[from]; p != 0 {
+				if c, t := p.identify(); c == col && (t == pQUEEN || t == pROOK) {
+					return true
+				}
+				break
+			}
+		}
+	}
+	pawnCaptures := dDIAGONAL[2:]
+	if col == cBLACK {
+		pawnCaptures = dDIAGONAL[0:2]
+	}
+	for _, d := range pawnCaptures {
+		if c, t := b.sq[sq+d].identify(); c == col && t == pPAWN {
+			return true
+		}
+	}
+	return false
+}
+
+// AttackersOf returns the squares of the pieces of a side that attack square.
+// It returns nil if square is not a valid square name
+func (b *Board) AttackersOf(square string, white bool) []string {
+	sq, err := parseSquare(square)
+	if err != nil {
+		return nil
+	}
+	attackers := b.attackersOf(sq, colorOf(white))
+	s := make([]string, len(attackers))
+	for i, a := range attackers {
+		s[i] = sq2string(a)
+	}
+	return s
+}
+
+// lineStep returns the step that leads from from to to along
+// a rank, a file or a diagonal, or 0 if they share no line
+func lineStep(from, to int8) int8 {
+	df, dr := to%10-from%10, to/10-from/10
+	sign := func(n int8) int8 {
+		if n < 0 {
+			return -1
+		} else if n > 0 {
+			return 1
+		}
+		return 0
+	}
+	if from == to || (df != 0 && dr != 0 && df != dr && df != -dr) {
+		return 0
+	}
+	return 10*sign(dr) + sign(df)
+}
+
+// IsClearLine reports whether from and to share a rank, a file
+// or a diagonal and all the squares between them are empty
+func (b *Board) IsClearLine(from, to string) bool {
+	f, err := parseSquare(from)
+	if err != nil {
+		return false
+	}
+	t, err := parseSquare(to)
+	if err != nil {
+		return false
+	}
+	step := lineStep(f, t)
+	if step == 0 {
+		return false
+	}
+	for sq := f + step; sq != t; sq += step {
+		if b.sq[sq] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (b *Board) piecesMovableTo(sq int8, col color) []int8 {
 	s := b.attackersOf(sq, col)
 	direction := int8(-1)
@@ -266,24 +534,208 @@ func (b *Board) piecesMovableTo(sq int8, col color) []int8 {
 	return s
 }
 
+// reaches reports whether the piece on from can move to to according
+// to how it moves. It does not check if the move leaves the king in check
+func (b *Board) reaches(from, to int8) bool {
+	if b.sq[to] == 0xff || b.sq[from] == 0 || b.sq[from] == 0xff {
+		return false
+	}
+	col, typ := b.sq[from].identify()
+	if b.sq[to] != 0 {
+		if c, _ := b.sq[to].identify(); c == col {
+			return false
+		}
+	}
+	if typ != pPAWN {
+		for _, sq := range b.attackersOf(to, col) {
+			if sq == from {
+				return true
+			}
+		}
+		return false
+	}
+	step, home := int8(10), int8(3)
+	if col == cBLACK {
+		step, home = -10, 8
+	}
+	switch to - from {
+	case step:
+		return b.sq[to] == 0
+	case 2 * step:
+		return from/10 == home && b.sq[from+step] == 0 && b.sq[to] == 0
+	case step - 1, step + 1:
+		return b.sq[to] != 0 || (b.epsq != 0 && to == b.epsq)
+	}
+	return false
+}
+
+// isLegal reports whether the piece on from can move to to
+// without leaving its king in check
+func (b *Board) isLegal(from, to int8) bool {
+	if !b.reaches(from, to) {
+		return false
+	}
+	col, _ := b.sq[from].identify()
+	return b.tryMove(true, col, from, to, "") == nil
+}
+
+// disambiguation returns the part of the SAN that tells apart the move
+// from from to to from the moves of other pieces of the same type
+func (b *Board) disambiguation(from, to int8) string {
+	col, typ := b.sq[from].identify()
+	if typ == pPAWN {
+		if from%10 != to%10 {
+			return sq2string(from)[:1]
+		}
+		return ""
+	}
+	others := make([]int8, 0)
+	for _, sq := range b.attackersOf(to, col) {
+		if _, t := b.sq[sq].identify(); sq != from && t == typ && b.isLegal(sq, to) {
+			others = append(others, sq)
+		}
+	}
+	if len(others) == 0 {
+		return ""
+	}
+	sameFile, sameRank := false, false
+	for _, sq := range others {
+		sameFile = sameFile || sq%10 == from%10
+		sameRank = sameRank || sq/10 == from/10
+	}
+	if !sameFile {
+		return sq2string(from)[:1]
+	}
+	if !sameRank {
+		return sq2string(from)[1:]
+	}
+	return sq2string(from)
+}
+
+// DisambiguationFor returns the minimal disambiguation needed in SAN for
+// a move of the piece of type pieceType, one of PNBRQK, from fromSquare
+// to toSquare. It is empty, a file, a rank or the full fromSquare.
+// It returns an error if there is no such piece or it cannot legally move there
+func (b *Board) DisambiguationFor(pieceType, fromSquare, toSquare string) (string, error) {
+	from, err := parseSquare(fromSquare)
+	if err != nil {
+		return "", err
+	}
+	to, err := parseSquare(toSquare)
+	if err != nil {
+		return "", err
+	}
+	typ := strings.Index("PNBRQK", pieceType) + 1
+	if len(pieceType) != 1 || typ == 0 {
+		return "", fmt.Errorf("piece type %q is not valid", pieceType)
+	}
+	if _, t := b.sq[from].identify(); b.sq[from] == 0 || t != uint8(typ) {
+		return "", fmt.Errorf("there is no %s on %s", pieceType, fromSquare)
+	}
+	if !b.isLegal(from, to) {
+		return "", fmt.Errorf("%s on %s cannot move to %s", pieceType, fromSquare, toSquare)
+	}
+	return b.disambiguation(from, to), nil
+}
+
 // MakeMove makes a move on the board
-// If the move is illegal like a king move to a checked square or the move is ambiguous
+// If the move is illegal like a king move to a checked square or castling through one, or the move is ambiguous
 // as if two pieces can move to the same square, then it returns an error and the board
 // does not record the move. The board keeps track of which color moved previously and
-// alternates
+// alternates. The null move -- passes the turn without moving any piece, it advances
+// the move counters and clears the en passant square
 func (b *Board) MakeMove(san string) error {
-	err := b.makeMoveFor(san, b.activeMove)
+	m, err := b.resolveSAN(san, b.activeMove)
 	if err == nil {
-		if b.activeMove == cBLACK {
-			b.MoveNumber++
-		}
-		b.activeMove = b.activeMove.opposite()
-		b.MoveWhite = !b.MoveWhite
-		b.lastSAN = san
+		b.makeMove(m, san)
 	}
 	return err
 }
 
+// Undo takes back the last move made on the board and restores
+// the state before it, including the counters, the castling rights
+// and the en passant square. It is an error if no move was made
+func (b *Board) Undo() error {
+	return b.UndoN(1)
+}
+
+// UndoN takes back the last n moves made on the board. It is an error,
+// and the board does not change, if fewer than n moves were made.
+// The piece values of the board stay as they are
+func (b *Board) UndoN(n int) error {
+	if n < 0 || n > len(b.history) {
+		return fmt.Errorf("cannot undo %d moves, there are %d", n, len(b.history))
+	}
+	if n == 0 {
+		return nil
+	}
+	// the piece values are a setting of the board, not part of the position
+	history, values := b.history[:len(b.history)-n], b.Values
+	*b = b.history[len(b.history)-n]
+	b.history, b.Values = history, values
+	b.linkPlay()
+	b.status = ""
+	return nil
+}
+
+// FENHistory returns the FEN of each position of the board since it
+// was constructed, the starting position first and the current last.
+// Moves taken back with Undo are not included
+func (b *Board) FENHistory() []string {
+	fens := make([]string, 0, len(b.history)+1)
+	for _, h := range b.history {
+		h.linkPlay()
+		fens = append(fens, h.Fen())
+	}
+	return append(fens, b.Fen())
+}
+
+// MakeMoves makes the moves in turn and returns the FEN after each one.
+// It stops at the first illegal move and returns the FENs up to it with
+// an error that names the move. The moves made stay on the board
+func (b *Board) MakeMoves(sans []string) ([]string, error) {
+	fens := make([]string, 0, len(sans))
+	for i, san := range sans {
+		if err := b.MakeMove(san); err != nil {
+			return fens, fmt.Errorf("move %d: %s", i+1, err)
+		}
+		fens = append(fens, b.Fen())
+	}
+	return fens, nil
+}
+
+// makeMove plays the legal move m for the side to move
+// and updates the move counters
+func (b *Board) makeMove(m move, san string) {
+	prev := *b
+	prev.history = nil
+	prev.captured = b.captured[:len(b.captured):len(b.captured)]
+	b.history = append(b.history, prev)
+
+	b.halfmoves++
+	if m.from != 0 {
+		if _, typ := b.sq[m.from].identify(); typ == pPAWN || b.isCapture(m) {
+			b.halfmoves = 0
+		}
+		if b.isCapture(m) && !b.isCastling(m) {
+			if b.sq[m.to] != 0 {
+				b.captured = append(b.captured, b.sq[m.to])
+			} else if m.to == b.epsq {
+				b.captured = append(b.captured, newPiece(b.activeMove.opposite(), pPAWN, true))
+			}
+		}
+	}
+	b.applyMove(m, b.activeMove)
+	if b.activeMove == cBLACK {
+		b.MoveNumber++
+	}
+	b.activeMove = b.activeMove.opposite()
+	b.MoveWhite = !b.MoveWhite
+	b.lastSAN = san
+	b.lastMove = m
+	b.status = ""
+}
+
 // LastMove returns the last move made on the board.
 // In other words the position on the board resulted after this move
 func (b *Board) LastMove() (san string, white bool, number uint8) {
@@ -297,42 +749,178 @@ func (b *Board) LastMove() (san string, white bool, number uint8) {
 	return
 }
 
+// LastMoveSquares returns the squares the last move made on the board
+// moved a piece from and to. For castling these are the king squares.
+// ok is false if no move has been made or the last move was a null move
+func (b *Board) LastMoveSquares() (from, to string, ok bool) {
+	if b.lastMove.from == 0 {
+		return "", "", false
+	}
+	return sq2string(b.lastMove.from), sq2string(b.lastMove.to), true
+}
+
+// CapturedPieces returns the pieces captured by the moves made on the
+// board, as FEN letters in the order of capture. white has the captured
+// white pieces and black the captured black pieces
+func (b *Board) CapturedPieces() (white, black []string) {
+	for _, p := range b.captured {
+		if col, _ := p.identify(); col == cWHITE {
+			white = append(white, p.String())
+		} else {
+			black = append(black, p.String())
+		}
+	}
+	return
+}
+
+// PieceMap returns the occupied squares of the board, like e4, with
+// the FEN letters of their pieces, like P for a white pawn
+func (b *Board) PieceMap() map[string]string {
+	pieces := make(map[string]string)
+	for sq, p := range b.sq {
+		if p != 0 && p != 0xff {
+			pieces[sq2string(int8(sq))] = p.String()
+		}
+	}
+	return pieces
+}
+
+// PromotedSquares returns the squares of the pieces that came from
+// a promotion of a pawn on this board. Pieces set up from FEN do not
+// count, as FEN does not record promotions
+func (b *Board) PromotedSquares() []string {
+	s := make([]string, 0)
+	for sq := int8(21); sq < 99; sq++ {
+		if p := b.sq[sq]; p != 0xff && p.isPromoted() {
+			s = append(s, sq2string(sq))
+		}
+	}
+	return s
+}
+
+// PieceCount returns the number of pieces of both colors on the board, kings included
+func (b *Board) PieceCount() int {
+	return b.PieceCountByColor(true) + b.PieceCountByColor(false)
+}
+
+// PieceCountByColor returns the number of pieces of a side on the board, king included
+func (b *Board) PieceCountByColor(white bool) int {
+	n := 0
+	for _, rank := range b.play {
+		for _, p := range rank {
+			if c, _ := p.identify(); p != 0 && c == colorOf(white) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Ply returns the number of half moves since the start of the game,
+// computed from MoveNumber and the side to move
+func (b *Board) Ply() int {
+	ply := 2 * (int(b.MoveNumber) - 1)
+	if b.activeMove == cBLACK {
+		ply++
+	}
+	return ply
+}
+
+// MoveNumberForPly returns the move number and the color of the
+// ply-th half move of a game, starting from 0 for the first move of white.
+// It is the inverse of Board.Ply
+func MoveNumberForPly(ply int) (fullmove int, white bool) {
+	return ply/2 + 1, ply%2 == 0
+}
+
+// MovesSinceProgress returns the number of full moves since the last
+// pawn move or capture, i.e the halfmove clock of the fifty-move rule in moves
+func (b *Board) MovesSinceProgress() int {
+	return b.halfmoves / 2
+}
+
+// MovePiece moves the piece on from to to without checking if the move
+// is legal, to edit positions. Whatever was on to is removed,
+// unless it is a king. It does not change the side to move
+func (b *Board) MovePiece(from, to string) error {
+	f, err := parseSquare(from)
+	if err != nil {
+		return err
+	}
+	t, err := parseSquare(to)
+	if err != nil {
+		return err
+	}
+	if b.sq[f] == 0 {
+		return fmt.Errorf("there is no piece on %s", from)
+	}
+	if _, typ := b.sq[t].identify(); f != t && b.sq[t] != 0 && typ == pKING {
+		return fmt.Errorf("cannot remove the king on %s", to)
+	}
+	p := b.sq[f]
+	b.sq[f], b.sq[t] = 0, p
+	b.status = ""
+	if col, typ := p.identify(); typ == pKING {
+		if col == cWHITE {
+			b.wksq = t
+		} else {
+			b.bksq = t
+		}
+	}
+	return nil
+}
+
+// KingSquare returns the square of the king of a side
+func (b *Board) KingSquare(white bool) string {
+	if white {
+		return sq2string(b.wksq)
+	}
+	return sq2string(b.bksq)
+}
+
 // SetTurn sets who makes the next move
 func (b *Board) SetTurn(whiteMove bool) {
 	b.activeMove = colorOf(whiteMove)
 	b.MoveWhite = whiteMove
+	b.status = ""
 }
 
-func (b *Board) makeMoveFor(san string, activeMove color) error {
-	if san == "--" {
-		return nil
+// FlipTurn passes the move to the other side and clears the en passant
+// square. The fullmove number goes up when black passes to white.
+// Unlike playing the null move -- it is not a move: it does not go to
+// the history for Undo, the halfmove clock and the last move do not change
+func (b *Board) FlipTurn() {
+	if b.activeMove == cBLACK {
+		b.MoveNumber++
 	}
-	if strings.HasPrefix(san, "O-O-O") {
-		if activeMove == cWHITE {
-			b.sq[21], b.sq[22], b.sq[23], b.sq[24], b.sq[25] = 0, 0, newPiece(cWHITE, pKING, true), newPiece(cWHITE, pROOK, true), 0
-			b.wksq = 23
-		} else {
-			b.sq[91], b.sq[92], b.sq[93], b.sq[94], b.sq[95] = 0, 0, newPiece(cBLACK, pKING, true), newPiece(cBLACK, pROOK, true), 0
-			b.bksq = 93
-		}
-		b.epsq = 0
-		return nil
+	b.SetTurn(b.activeMove == cBLACK)
+	b.epsq = 0
+}
+
+// resolveSAN finds the move san describes for activeMove.
+// The null move -- is the zero move
+func (b *Board) resolveSAN(san string, activeMove color) (move, error) {
+	if san == "--" {
+		return move{}, nil
 	}
 	if strings.HasPrefix(san, "O-O") {
-		if activeMove == cWHITE {
-			b.sq[25], b.sq[26], b.sq[27], b.sq[28] = 0, newPiece(cWHITE, pROOK, true), newPiece(cWHITE, pKING, true), 0
-			b.wksq = 27
-		} else {
-			b.sq[95], b.sq[96], b.sq[97], b.sq[98] = 0, newPiece(cBLACK, pROOK, true), newPiece(cBLACK, pKING, true), 0
-			b.bksq = 97
+		ksq := int8(25)
+		if activeMove == cBLACK {
+			ksq = 95
 		}
-		b.epsq = 0
-		return nil
+		kingside := !strings.HasPrefix(san, "O-O-O")
+		if problem := b.castlingProblem(activeMove, kingside); problem != "" {
+			return move{}, fmt.Errorf("cannot castle: %s", problem)
+		}
+		if !kingside {
+			return move{ksq, ksq - 2, 0}, nil
+		}
+		return move{ksq, ksq + 2, 0}, nil
 	}
 
 	matches := rSANRE.FindStringSubmatch(san)
 	if matches == nil || len(matches) != 5 {
-		return fmt.Errorf("san %q is not a valid move", san)
+		return move{}, fmt.Errorf("san %q is not a valid move", san)
 	}
 	piece, fromHint, dsq, promotes := matches[1], matches[2], matches[3], matches[4]
 	if piece == "" {
@@ -343,17 +931,23 @@ func (b *Board) makeMoveFor(san string, activeMove color) error {
 	}
 	pieceTyp := uint8(strings.Index("PNBRQK", piece) + 1)
 	tosq := string2sq(dsq)
+	if lastRank := tosq/10 == 9 || tosq/10 == 2; pieceTyp == pPAWN && lastRank != (promotes != "") {
+		if lastRank {
+			return move{}, fmt.Errorf("the pawn must promote: SAN %s", san)
+		}
+		return move{}, fmt.Errorf("only pawns on the last rank promote: SAN %s", san)
+	}
 
 	candidates := b.piecesMovableTo(tosq, activeMove)
 	if candidates == nil {
-		return fmt.Errorf("no candidates to move for: SAN %s", san)
+		return move{}, fmt.Errorf("no candidates to move for: SAN %s", san)
 	}
 
 	qualified := make([]int8, 0)
 	for _, candidate := range candidates {
 		if _, typ := b.sq[candidate].identify(); typ == pieceTyp {
 			if fromHint == "" || strings.Index(sq2string(candidate), fromHint) >= 0 {
-				if b.tryMove(true, activeMove, candidate, tosq, promotes) == nil {
+				if b.reaches(candidate, tosq) && b.tryMove(true, activeMove, candidate, tosq, promotes) == nil {
 					qualified = append(qualified, candidate)
 				}
 			}
@@ -364,9 +958,50 @@ func (b *Board) makeMoveFor(san string, activeMove color) error {
 //		for _, sq := range candidates {
 //			fmt.Println("\t", sq2string(sq))
 //		}
-		return fmt.Errorf("there are %d candidate moves for %d %s", len(qualified), b.MoveNumber, san)
+		return move{}, fmt.Errorf("there are %d candidate moves for %d %s", len(qualified), b.MoveNumber, san)
+	}
+	m := move{qualified[0], tosq, 0}
+	if promotes != "" {
+		m.promotes = uint8(strings.Index("PNBRQK", promotes[1:2]) + 1)
 	}
-	return b.tryMove(false, activeMove, qualified[0], tosq, promotes)
+	return m, nil
+}
+
+// applyMove plays the move m, which must be legal, for activeMove.
+// It does not update the move counters. The zero move is the null move
+// which only passes the turn, so an en passant capture is no longer possible
+func (b *Board) applyMove(m move, activeMove color) {
+	if m.from == 0 {
+		b.epsq = 0
+		return
+	}
+	if b.isCastling(m) && m.to < m.from {
+		if activeMove == cWHITE {
+			b.sq[21], b.sq[22], b.sq[23], b.sq[24], b.sq[25] = 0, 0, newPiece(cWHITE, pKING, true), newPiece(cWHITE, pROOK, true), 0
+			b.wksq = 23
+		} else {
+			b.sq[91], b.sq[92], b.sq[93], b.sq[94], b.sq[95] = 0, 0, newPiece(cBLACK, pKING, true), newPiece(cBLACK, pROOK, true), 0
+			b.bksq = 93
+		}
+		b.epsq = 0
+		return
+	}
+	if b.isCastling(m) {
+		if activeMove == cWHITE {
+			b.sq[25], b.sq[26], b.sq[27], b.sq[28] = 0, newPiece(cWHITE, pROOK, true), newPiece(cWHITE, pKING, true), 0
+			b.wksq = 27
+		} else {
+			b.sq[95], b.sq[96], b.sq[97], b.sq[98] = 0, newPiece(cBLACK, pROOK, true), newPiece(cBLACK, pKING, true), 0
+			b.bksq = 97
+		}
+		b.epsq = 0
+		return
+	}
+	promotes := ""
+	if m.promotes != 0 {
+		promotes = "=" + string("PNBRQK"[m.promotes-1])
+	}
+	b.tryMove(false, activeMove, m.from, m.to, promotes)
 }
 
 func (b *Board) tryMove(try bool, activeMove color, csq, tosq int8, promotes string) error {
@@ -375,8 +1010,13 @@ func (b *Board) tryMove(try bool, activeMove color, csq, tosq int8, promotes str
 		step = int8(10)
 	}
 
-	// TODO rollback ep moves
 	if try {
+		// the pawn an en passant capture removes
+		if b.epsq != 0 {
+			defer func(sq int8, p piece) {
+				b.sq[sq] = p
+			}(b.epsq-step, b.sq[b.epsq-step])
+		}
 		defer func(tosq, csq, epsq, wksq, bksq int8, tosqp, csqp piece) {
 			b.sq[tosq] = tosqp
 			b.sq[csq] = csqp
@@ -397,7 +1037,7 @@ func (b *Board) tryMove(try bool, activeMove color, csq, tosq int8, promotes str
 			b.epsq = 0
 		}
 		if promotes != "" {
-			cPiece = newPiece(activeMove, uint8(strings.Index("PNBRQK", promotes[1:2])+1), true)
+			cPiece = newPiece(activeMove, uint8(strings.Index("PNBRQK", promotes[1:2])+1), true) | 0x10
 		}
 		b.sq[tosq] = cPiece.markedMoved()
 	} else {
@@ -424,8 +1064,66 @@ func (b *Board) tryMove(try bool, activeMove color, csq, tosq int8, promotes str
 	return nil
 }
 
+// castlingRights returns the castling availability as in FEN.
+// A side keeps a right while its king and the rook have not moved
+func (b *Board) castlingRights() string {
+	av := ""
+	for i, corner := range []int8{28, 21, 98, 91} {
+		col, home := color(cWHITE), int8(25)
+		if i >= 2 {
+			col, home = cBLACK, 95
+		}
+		if b.sq[home] == newPiece(col, pKING, false) && b.sq[corner] == newPiece(col, pROOK, false) {
+			av += string("KQkq"[i])
+		}
+	}
+	if av == "" {
+		av = "-"
+	}
+	return av
+}
+
+// IsInitialPosition reports whether the board is at the standard
+// starting position, with white to move, all castling rights,
+// no en passant square and the move counters at 0 and 1
+func (b *Board) IsInitialPosition() bool {
+	initial := NewBoard()
+	for sq := range b.sq {
+		if b.sq[sq] != initial.sq[sq] && b.sq[sq]&^0x08 != initial.sq[sq] {
+			return false
+		}
+	}
+	return b.activeMove == cWHITE && b.castlingRights() == "KQkq" &&
+		b.epsq == 0 && b.halfmoves == 0 && b.MoveNumber == 1
+}
+
+// RequiresSetUpTag reports whether a game starting from the board needs
+// the SetUp and FEN tags in PGN, because it does not start from the
+// initial position. If so it also returns the value of the FEN tag
+func (b *Board) RequiresSetUpTag() (bool, string) {
+	if b.IsInitialPosition() {
+		return false, ""
+	}
+	return true, b.Fen()
+}
+
 // Fen returns the board position as a standard FEN string see http://en.wikipedia.org/wiki/Forsyth%E2%80%93Edwards_Notation
 func (b *Board) Fen() string {
+	fen := b.FenShort()
+
+	// halfmoves
+	fen += " " + strconv.Itoa(b.halfmoves)
+
+	// full moves
+	fen += " " + strconv.Itoa(int(b.MoveNumber))
+
+	return fen
+}
+
+// FenShort returns the first four fields of the FEN: the piece placement,
+// the active color, the castling availability and the en passant target.
+// Positions that differ only in the move counters have the same FenShort
+func (b *Board) FenShort() string {
 	fen := ""
 
 	// piece placement
@@ -455,42 +1153,191 @@ func (b *Board) Fen() string {
 	fen += " " + b.activeMove.String()
 
 	// castling availability
-	av := " "
-	if !b.sq[b.wksq].hasMoved() {
-		if !b.sq[21].hasMoved() {
-			av += "Q"
-		}
-		if !b.sq[28].hasMoved() {
-			av += "K"
-		}
-	}
-	if !b.sq[b.bksq].hasMoved() {
-		if !b.sq[91].hasMoved() {
-			av += "q"
-		}
-		if !b.sq[98].hasMoved() {
-			av += "k"
-		}
-	}
-	if av == " " {
-		av = " -"
-	}
-	fen += av
+	fen += " " + b.castlingRights()
 	
 	// en passant target
 	if b.epsq == 0 {
 		fen += " -"
 	} else {
-		fen += "  " + sq2string(b.epsq)
+		fen += " " + sq2string(b.epsq)
 	}
 
-	// halfmoves TODO
-	fen += " 0"
+	return fen
+}
 
-	// full moves
-	fen += " " + strconv.Itoa(int(b.MoveNumber))
+// IsEnPassantAvailable reports whether the board has an en passant square
+// and a pawn of the side to move stands next to the pawn that can be
+// captured. FENs often list the square after every double pawn step
+// even when no capture is possible. Pins are not considered
+func (b *Board) IsEnPassantAvailable() bool {
+	if b.epsq == 0 {
+		return false
+	}
+	behind := b.epsq - 10
+	if b.activeMove == cBLACK {
+		behind = b.epsq + 10
+	}
+	pawn := newPiece(b.activeMove, pPAWN, false)
+	return b.sq[behind-1]&^0x08 == pawn || b.sq[behind+1]&^0x08 == pawn
+}
 
-	return fen
+// FenWithTurn returns the position as FEN like Fen but with white or black
+// to move. The board does not change. The en passant square is kept only
+// if it can be captured by the chosen side, that is only on the sixth rank
+// for white and the third for black
+func (b *Board) FenWithTurn(white bool) string {
+	fields := strings.Fields(b.Fen())
+	fields[1] = colorOf(white).String()
+	if (white && b.epsq/10 != 7) || (!white && b.epsq/10 != 4) {
+		fields[3] = "-"
+	}
+	return strings.Join(fields, " ")
+}
+
+// CanonicalFEN returns the position as FEN like Fen but with the en passant
+// square only when an en passant capture is legal and with the castling
+// rights that the king and rook placement still allows. Equal positions
+// then have equal FENs, as reference engines print them
+func (b *Board) CanonicalFEN() string {
+	fields := strings.Fields(b.Fen())
+	fields[2] = b.castlingRights()
+	fields[3] = "-"
+	if b.IsEnPassantAvailable() {
+		for _, m := range b.legalMoves() {
+			if m.to == b.epsq && m.from%10 != m.to%10 {
+				if _, typ := b.sq[m.from].identify(); typ == pPAWN {
+					fields[3] = sq2string(b.epsq)
+					break
+				}
+			}
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// FENDiff returns the differences between the positions of two FENs, one
+// per line like "e4 empty→white pawn" for the squares, in the order of
+// FEN, followed by the fields: side to move, castling, en passant,
+// halfmove clock and fullmove number, like "side to move w→b"
+func FENDiff(a, b string) ([]string, error) {
+	ba, err := NewBoardFromFen(a)
+	if err != nil {
+		return nil, err
+	}
+	bb, err := NewBoardFromFen(b)
+	if err != nil {
+		return nil, err
+	}
+	describe := func(p piece) string {
+		if p == 0 {
+			return "empty"
+		}
+		col, typ := p.identify()
+		return boolAsColor(col == cWHITE) + " " + pieceNames[typ]
+	}
+	diffs := make([]string, 0)
+	for r := range ba.play {
+		for f := range ba.play[r] {
+			pa, pb := ba.play[r][f]&^0x08, bb.play[r][f]&^0x08
+			if pa != pb {
+				diffs = append(diffs, playSquare(r, f)+" "+describe(pa)+"→"+describe(pb))
+			}
+		}
+	}
+	fa, fb := strings.Fields(ba.Fen()), strings.Fields(bb.Fen())
+	for i, name := range []string{"side to move", "castling", "en passant", "halfmove clock", "fullmove number"} {
+		if fa[i+1] != fb[i+1] {
+			diffs = append(diffs, name+" "+fa[i+1]+"→"+fb[i+1])
+		}
+	}
+	return diffs, nil
+}
+
+// RepetitionKey returns a hash of the parts of the position that count for
+// repetitions: the placement, the side to move, the castling rights and the
+// en passant square if a capture there is legal. The move counters are left
+// out so positions that repeat have the same key
+func (b *Board) RepetitionKey() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(strings.Fields(b.CanonicalFEN())[:4], " ")))
+	return h.Sum64()
+}
+
+// CanonicalKey returns a hash of the position that is the same for the
+// positions that are symmetric to it: its mirror across the d and e files,
+// when no side can castle, and, when both sides have the same material, the
+// position with the colors swapped and the board turned upside down. It is
+// the smallest RepetitionKey of these positions and it helps find puzzles
+// that are reflections of each other
+func (b *Board) CanonicalKey() uint64 {
+	fields := strings.Fields(b.CanonicalFEN())
+	boards := []*Board{b}
+	if fields[2] == "-" {
+		boards = append(boards, b.transformed(true, false))
+	}
+	var material [2][7]int
+	for _, p := range b.sq {
+		if c, t := p.identify(); p != 0 && p != 0xff {
+			material[c][t]++
+		}
+	}
+	if material[cWHITE] == material[cBLACK] {
+		for _, t := range boards {
+			boards = append(boards, t.transformed(false, true))
+		}
+	}
+	key := b.RepetitionKey()
+	for _, t := range boards {
+		if k := t.RepetitionKey(); k < key {
+			key = k
+		}
+	}
+	return key
+}
+
+// transformed returns a copy of the board mirrored across the d and e
+// files, or with the colors swapped and the board turned upside down,
+// so that the position is the same for the side to move
+func (b *Board) transformed(mirror, swap bool) *Board {
+	t := b.clone()
+	for sq := 21; sq < 99; sq++ {
+		if b.sq[sq] == 0xff {
+			continue
+		}
+		r, f := sq/10, sq%10
+		if mirror {
+			f = 9 - f
+		}
+		if swap {
+			r = 11 - r
+		}
+		p := b.sq[sq]
+		if swap && p != 0 {
+			p ^= 0x80
+		}
+		t.sq[r*10+f] = p
+		if p == newPiece(cWHITE, pKING, false) || p == newPiece(cWHITE, pKING, true) {
+			t.wksq = int8(r*10 + f)
+		} else if p == newPiece(cBLACK, pKING, false) || p == newPiece(cBLACK, pKING, true) {
+			t.bksq = int8(r*10 + f)
+		}
+	}
+	if t.epsq != 0 {
+		r, f := t.epsq/10, t.epsq%10
+		if mirror {
+			f = 9 - f
+		}
+		if swap {
+			r = 11 - r
+		}
+		t.epsq = r*10 + f
+	}
+	if swap {
+		t.activeMove = t.activeMove.opposite()
+		t.MoveWhite = !t.MoveWhite
+	}
+	t.status = ""
+	return t
 }
 
 func min(a, b int) int {
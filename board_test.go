@@ -0,0 +1,790 @@
+package gochess
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMakeMoveCastling(t *testing.T) {
+	b := NewBoard()
+	fen := b.Fen()
+	if err := b.MakeMove("O-O"); err == nil {
+		t.Errorf("O-O in the initial position succeeded: %s", b.Fen())
+	}
+	if b.Fen() != fen {
+		t.Errorf("board changed after illegal castling: %s", b.Fen())
+	}
+
+	tests := []struct {
+		fen, san string
+		ok       bool
+	}{
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O-O", true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", true},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w Qkq - 0 1", "O-O", false},
+		{"r3k2r/8/8/8/8/8/5r2/R3K2R w KQkq - 0 1", "O-O", false},
+		{"r3k2r/8/8/8/8/8/8/R3K1NR w KQkq - 0 1", "O-O", false},
+		{"r3k2r/8/8/8/8/8/4r3/R3K2R w KQkq - 0 1", "O-O-O", false},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		if err := b.MakeMove(tt.san); (err == nil) != tt.ok {
+			t.Errorf("%s in %s: got error %v, want ok %v", tt.san, tt.fen, err, tt.ok)
+		}
+	}
+}
+
+func TestUndoN(t *testing.T) {
+	b := NewBoard()
+	moves := []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6"}
+	fens := []string{b.Fen()}
+	for _, san := range moves {
+		if err := b.MakeMove(san); err != nil {
+			t.Fatalf("MakeMove(%s): %v", san, err)
+		}
+		fens = append(fens, b.Fen())
+	}
+	if err := b.UndoN(4); err != nil {
+		t.Fatal(err)
+	}
+	if b.Fen() != fens[2] {
+		t.Errorf("after UndoN(4) = %s, want %s", b.Fen(), fens[2])
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify() after UndoN(4): %v", err)
+	}
+	if err := b.UndoN(3); err == nil || b.Fen() != fens[2] {
+		t.Errorf("UndoN(3) with 2 moves = %v, board %s", err, b.Fen())
+	}
+	if err := b.MakeMove("Nf3"); err != nil || b.Fen() != fens[3] {
+		t.Errorf("replaying Nf3 after UndoN = %v, board %s", err, b.Fen())
+	}
+	if err := b.UndoN(3); err != nil || b.Fen() != fens[0] {
+		t.Errorf("UndoN(3) to the start = %v, board %s", err, b.Fen())
+	}
+	if err := b.Undo(); err == nil {
+		t.Errorf("Undo() in the initial position succeeded")
+	}
+}
+
+func TestCloneHasNoHistory(t *testing.T) {
+	b := NewBoard()
+	for _, san := range []string{"d4", "d5", "c4"} {
+		if err := b.MakeMove(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := b.clone()
+	if len(c.history) != 0 {
+		t.Errorf("clone has %d history entries", len(c.history))
+	}
+	if err := c.MakeMove("dxc4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Undo(); err != nil || c.Fen() != b.Fen() {
+		t.Errorf("Undo() on the clone = %v, board %s, want %s", err, c.Fen(), b.Fen())
+	}
+	if white, black := b.CapturedPieces(); len(b.history) != 3 || len(white)+len(black) != 0 {
+		t.Errorf("the clone changed the board")
+	}
+}
+
+func TestDisambiguationFor(t *testing.T) {
+	tests := []struct {
+		fen, piece, from, to, want string
+	}{
+		{"4k3/R7/8/8/8/8/8/R3K3 w - - 0 1", "R", "a1", "a4", "1"},
+		{"4k3/R7/8/8/8/8/8/R3K3 w - - 0 1", "R", "a7", "a4", "7"},
+		{"4k3/8/8/8/8/8/8/R4RK1 w - - 0 1", "R", "a1", "d1", "a"},
+		{"4k3/8/8/8/8/8/8/R3K3 w - - 0 1", "R", "a1", "a4", ""},
+		{"4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "N", "b1", "d2", "b"},
+		{"4k3/8/8/Q6Q/8/8/8/Q3K3 w - - 0 1", "Q", "h5", "e2", ""},
+		{"4k3/8/8/8/Q2Q4/8/8/Q6K w - - 0 1", "Q", "a4", "d1", "a4"},
+		{"4k3/8/8/8/Q2Q4/8/8/Q6K w - - 0 1", "Q", "d4", "d1", "d"},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		s, err := b.DisambiguationFor(tt.piece, tt.from, tt.to)
+		if err != nil || s != tt.want {
+			t.Errorf("DisambiguationFor(%s, %s, %s) in %s = %q, %v, want %q", tt.piece, tt.from, tt.to, tt.fen, s, err, tt.want)
+		}
+	}
+	if _, err := NewBoard().DisambiguationFor("R", "a2", "a3"); err == nil {
+		t.Errorf("DisambiguationFor with no rook on a2 succeeded")
+	}
+}
+
+func TestEnPassantExposingTheKing(t *testing.T) {
+	b := mustFEN(t, "8/8/8/KPp4r/8/8/8/7k w - c6 0 1")
+	fen := b.Fen()
+	if err := b.MakeMove("bxc6"); err == nil {
+		t.Fatalf("en passant capture exposing the king succeeded: %s", b.Fen())
+	}
+	if b.Fen() != fen {
+		t.Errorf("board changed after the rejected capture: %s, want %s", b.Fen(), fen)
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify(): %v", err)
+	}
+	if err := b.MakeMove("b6"); err != nil {
+		t.Errorf("b6 after the rejected capture: %v", err)
+	}
+
+	b = mustFEN(t, "8/8/8/1KPp4/7r/8/8/7k w - d6 0 1")
+	if err := b.MakeMove("cxd6"); err != nil {
+		t.Fatalf("en passant capture: %v", err)
+	}
+	if want := "8/8/3P4/1K6/7r/8/8/7k b - - 0 1"; b.Fen() != want {
+		t.Errorf("after cxd6 = %s, want %s", b.Fen(), want)
+	}
+}
+
+func TestMovesSinceProgress(t *testing.T) {
+	b := mustFEN(t, "4k3/8/8/8/8/8/8/4K2R w - - 80 100")
+	if n := b.MovesSinceProgress(); n != 40 {
+		t.Errorf("MovesSinceProgress() = %d, want 40", n)
+	}
+	if err := b.MakeMove("Rh2"); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.MovesSinceProgress(); n != 40 {
+		t.Errorf("MovesSinceProgress() after Rh2 = %d, want 40", n)
+	}
+	if err := b.MakeMove("Kd7"); err != nil {
+		t.Fatal(err)
+	}
+	if n := b.MovesSinceProgress(); n != 41 {
+		t.Errorf("MovesSinceProgress() after Kd7 = %d, want 41", n)
+	}
+	b = mustFEN(t, "4k3/8/8/8/8/8/4P3/4K3 w - - 31 60")
+	if err := b.MakeMove("e4"); err != nil || b.MovesSinceProgress() != 0 {
+		t.Errorf("MovesSinceProgress() after a pawn move = %d, %v, want 0", b.MovesSinceProgress(), err)
+	}
+}
+
+func TestLastMoveSquares(t *testing.T) {
+	b := NewBoard()
+	if _, _, ok := b.LastMoveSquares(); ok {
+		t.Errorf("LastMoveSquares() before any move is ok")
+	}
+	if err := b.MakeMove("Nf3"); err != nil {
+		t.Fatal(err)
+	}
+	if from, to, ok := b.LastMoveSquares(); from != "g1" || to != "f3" || !ok {
+		t.Errorf("LastMoveSquares() after Nf3 = %s, %s, %v, want g1, f3, true", from, to, ok)
+	}
+	if err := b.MakeMove("--"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := b.LastMoveSquares(); ok {
+		t.Errorf("LastMoveSquares() after a null move is ok")
+	}
+}
+
+func TestNewBoardFromMoves(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "e5", "Nf3", "Nc6", "Bb5", "a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "r1bqkbnr/1ppp1ppp/p1n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 0 4"; b.Fen() != want {
+		t.Errorf("Fen() = %s, want %s", b.Fen(), want)
+	}
+	if _, err := NewBoardFromMoves("e4", "e5", "Ke3"); err == nil || !strings.HasPrefix(err.Error(), "move 3:") {
+		t.Errorf("illegal third move: %v", err)
+	}
+}
+
+func TestIsInitialPosition(t *testing.T) {
+	b := NewBoard()
+	if !b.IsInitialPosition() {
+		t.Errorf("IsInitialPosition() of NewBoard() is false")
+	}
+	if err := b.MakeMove("Nf3"); err != nil {
+		t.Fatal(err)
+	}
+	if b.IsInitialPosition() {
+		t.Errorf("IsInitialPosition() after Nf3 is true")
+	}
+	for _, fen := range []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w Kkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 4 3",
+	} {
+		if mustFEN(t, fen).IsInitialPosition() {
+			t.Errorf("IsInitialPosition() of %s is true", fen)
+		}
+	}
+	if !mustFEN(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1").IsInitialPosition() {
+		t.Errorf("IsInitialPosition() of the initial FEN is false")
+	}
+}
+
+func TestAttackersOf(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "e5", "Nf3", "Nc6", "d4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := b.AttackersOf("e5", true); !sameSquares(s, []string{"f3", "d4"}) {
+		t.Errorf("white attackers of e5 = %v, want f3 d4", s)
+	}
+	if s := b.AttackersOf("d4", false); !sameSquares(s, []string{"c6", "e5"}) {
+		t.Errorf("black attackers of d4 = %v, want c6 e5", s)
+	}
+	if s := b.AttackersOf("h4", false); len(s) != 1 || s[0] != "d8" {
+		t.Errorf("black attackers of h4 = %v, want d8", s)
+	}
+	if s := b.AttackersOf("z9", true); s != nil {
+		t.Errorf("attackers of an invalid square = %v, want nil", s)
+	}
+}
+
+func TestIsClearLine(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "d5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		from, to string
+		clear    bool
+	}{
+		{"e2", "e4", true},
+		{"e4", "e7", true},
+		{"e1", "e5", false},
+		{"f1", "a6", true},
+		{"c1", "h6", false},
+		{"d8", "d5", true},
+		{"d8", "d2", false},
+		{"a3", "h3", true},
+		{"g1", "f3", false},
+		{"e4", "e5", true},
+	}
+	for _, tt := range tests {
+		if clear := b.IsClearLine(tt.from, tt.to); clear != tt.clear {
+			t.Errorf("IsClearLine(%s, %s) = %v, want %v", tt.from, tt.to, clear, tt.clear)
+		}
+	}
+}
+
+func TestCapturedPieces(t *testing.T) {
+	b, err := NewBoardFromMoves("d4", "e5", "dxe5", "d6", "exd6", "Qxd6", "Qxd6", "cxd6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	white, black := b.CapturedPieces()
+	if !reflect.DeepEqual(white, []string{"P", "Q"}) || !reflect.DeepEqual(black, []string{"p", "p", "q"}) {
+		t.Errorf("CapturedPieces() = %v, %v, want [P Q], [p p q]", white, black)
+	}
+	if err := b.UndoN(2); err != nil {
+		t.Fatal(err)
+	}
+	white, black = b.CapturedPieces()
+	if !reflect.DeepEqual(white, []string{"P"}) || !reflect.DeepEqual(black, []string{"p", "p"}) {
+		t.Errorf("CapturedPieces() after UndoN(2) = %v, %v, want [P], [p p]", white, black)
+	}
+
+	b, err = NewBoardFromMoves("e4", "a6", "e5", "d5", "exd6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if white, black := b.CapturedPieces(); len(white) != 0 || !reflect.DeepEqual(black, []string{"p"}) {
+		t.Errorf("CapturedPieces() after en passant = %v, %v, want [], [p]", white, black)
+	}
+	b, err = NewBoardFromMoves("e4", "d5", "a3", "a6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.makeMove(move{string2sq("e4"), string2sq("f5"), 0}, "exf5")
+	if white, black := b.CapturedPieces(); len(white)+len(black) != 0 {
+		t.Errorf("CapturedPieces() after a pawn move to an empty square = %v, %v, want none", white, black)
+	}
+}
+
+func TestPly(t *testing.T) {
+	b := NewBoard()
+	if n := b.Ply(); n != 0 {
+		t.Errorf("Ply() of the initial position = %d, want 0", n)
+	}
+	for _, san := range []string{"e4", "e5", "Nf3"} {
+		if err := b.MakeMove(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := b.Ply(); n != 3 {
+		t.Errorf("Ply() after 1.e4 e5 2.Nf3 = %d, want 3", n)
+	}
+	if n := mustFEN(t, "4k3/8/8/8/8/8/8/4K3 b - - 0 40").Ply(); n != 79 {
+		t.Errorf("Ply() at move 40 of black = %d, want 79", n)
+	}
+}
+
+func TestFenShort(t *testing.T) {
+	a := mustFEN(t, "4k3/8/8/8/8/8/8/4K2R w K - 0 1")
+	b := mustFEN(t, "4k3/8/8/8/8/8/8/4K2R w K - 12 40")
+	if a.FenShort() != b.FenShort() {
+		t.Errorf("FenShort() differs: %q and %q", a.FenShort(), b.FenShort())
+	}
+	if want := "4k3/8/8/8/8/8/8/4K2R w K -"; a.FenShort() != want {
+		t.Errorf("FenShort() = %q, want %q", a.FenShort(), want)
+	}
+	if a.Fen() == b.Fen() {
+		t.Errorf("Fen() is the same for different counters: %q", a.Fen())
+	}
+	c, err := NewBoardFromMoves("e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3"; c.FenShort() != want {
+		t.Errorf("FenShort() after e4 = %q, want %q", c.FenShort(), want)
+	}
+}
+
+func TestMovePiece(t *testing.T) {
+	b := NewBoard()
+	if err := b.MovePiece("e1", "e4"); err != nil {
+		t.Fatal(err)
+	}
+	if sq := b.KingSquare(true); sq != "e4" {
+		t.Errorf("KingSquare(true) = %s, want e4", sq)
+	}
+	if err := b.MovePiece("d8", "d2"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "rnb1kbnr/pppppppp/8/8/4K3/8/PPPqPPPP/RNBQ1BNR w kq - 0 1"; b.Fen() != want {
+		t.Errorf("Fen() = %s, want %s", b.Fen(), want)
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify(): %v", err)
+	}
+	if err := b.MovePiece("e3", "e5"); err == nil {
+		t.Errorf("moving from an empty square succeeded")
+	}
+	if err := b.MovePiece("d2", "e4"); err == nil {
+		t.Errorf("capturing the king succeeded")
+	}
+	if err := b.MovePiece("e4", "z9"); err == nil {
+		t.Errorf("moving to an invalid square succeeded")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	b := NewBoard()
+	s := b.Snapshot()
+	if err := b.MovePiece("d1", "h5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.MovePiece("g8", "f6"); err != nil {
+		t.Fatal(err)
+	}
+	b.RestoreSnapshot(s)
+	if b.Fen() != NewBoard().Fen() {
+		t.Errorf("Fen() after RestoreSnapshot = %s", b.Fen())
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify(): %v", err)
+	}
+	if b.Render(RenderOptions{}) != NewBoard().Render(RenderOptions{}) {
+		t.Errorf("the ranks are not linked to the restored squares")
+	}
+}
+
+func TestToArray64(t *testing.T) {
+	a := NewBoard().ToArray64()
+	for i, want := range map[int]string{0: "R", 4: "K", 7: "R", 8: "P", 12: "P", 59: "q", 60: "k", 63: "r"} {
+		if a[i].String() != want {
+			t.Errorf("ToArray64()[%d] = %s, want %s", i, a[i], want)
+		}
+	}
+	for i := 16; i < 48; i++ {
+		if a[i] != 0 {
+			t.Errorf("ToArray64()[%d] = %s, want empty", i, a[i])
+		}
+	}
+}
+
+func TestRequiresSetUpTag(t *testing.T) {
+	if ok, fen := NewBoard().RequiresSetUpTag(); ok || fen != "" {
+		t.Errorf("RequiresSetUpTag() of the initial position = %v, %q", ok, fen)
+	}
+	want := "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"
+	if ok, fen := mustFEN(t, want).RequiresSetUpTag(); !ok || fen != want {
+		t.Errorf("RequiresSetUpTag() = %v, %q, want true, %q", ok, fen, want)
+	}
+}
+
+func TestIsEnPassantAvailable(t *testing.T) {
+	b, err := NewBoardFromMoves("e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.IsEnPassantAvailable() {
+		t.Errorf("IsEnPassantAvailable() after e4 with no black pawn to capture")
+	}
+	if b.Fen() != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1" {
+		t.Errorf("Fen() = %s", b.Fen())
+	}
+	if !mustFEN(t, "4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1").IsEnPassantAvailable() {
+		t.Errorf("IsEnPassantAvailable() with a pawn on d4 is false")
+	}
+	if mustFEN(t, "4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1").IsEnPassantAvailable() {
+		t.Errorf("IsEnPassantAvailable() without a capturing pawn is true")
+	}
+	if mustFEN(t, "4k3/8/8/8/3pP3/8/8/4K3 b - - 0 1").IsEnPassantAvailable() {
+		t.Errorf("IsEnPassantAvailable() without an en passant square is true")
+	}
+	if _, err := NewBoardFromFen("4k3/8/8/8/4P3/8/8/4K3 b - e4 0 1"); err == nil {
+		t.Errorf("en passant square on the fourth rank accepted")
+	}
+}
+
+func TestCanonicalFEN(t *testing.T) {
+	tests := []struct {
+		fen, want string
+	}{
+		{"4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1", "4k3/8/8/8/4P3/8/8/4K3 b - - 0 1"},
+		{"4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1", "4k3/8/8/8/3pP3/8/8/4K3 b - e3 0 1"},
+		{"8/8/8/8/k2pP2R/8/8/4K3 b - e3 0 1", "8/8/8/8/k2pP2R/8/8/4K3 b - - 0 1"},
+		{"4k3/8/8/8/8/8/8/4K3 w KQkq - 0 1", "4k3/8/8/8/8/8/8/4K3 w - - 0 1"},
+		{"r3k3/8/8/8/8/8/8/4K2R w KQkq - 0 1", "r3k3/8/8/8/8/8/8/4K2R w Kq - 0 1"},
+	}
+	for _, tt := range tests {
+		if fen := mustFEN(t, tt.fen).CanonicalFEN(); fen != tt.want {
+			t.Errorf("CanonicalFEN() of %s = %s, want %s", tt.fen, fen, tt.want)
+		}
+	}
+}
+
+func TestMakeMoves(t *testing.T) {
+	b := NewBoard()
+	fens, err := b.MakeMoves([]string{"d4", "Nf6", "c4", "e6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fens) != 4 {
+		t.Fatalf("MakeMoves() returned %d FENs, want 4", len(fens))
+	}
+	if want := "rnbqkb1r/pppp1ppp/4pn2/8/2PP4/8/PP2PPPP/RNBQKBNR w KQkq - 0 3"; fens[3] != want || b.Fen() != want {
+		t.Errorf("last FEN = %s, board %s, want %s", fens[3], b.Fen(), want)
+	}
+	fens, err = b.MakeMoves([]string{"Nc3", "Bb4", "Bb4"})
+	if err == nil || !strings.HasPrefix(err.Error(), "move 3:") || len(fens) != 2 {
+		t.Errorf("MakeMoves() with an illegal third move = %d FENs, %v", len(fens), err)
+	}
+}
+
+func TestPieceCount(t *testing.T) {
+	b := NewBoard()
+	if b.PieceCount() != 32 || b.PieceCountByColor(true) != 16 || b.PieceCountByColor(false) != 16 {
+		t.Errorf("PieceCount() of the initial position = %d, %d, %d", b.PieceCount(), b.PieceCountByColor(true), b.PieceCountByColor(false))
+	}
+	if _, err := b.MakeMoves([]string{"e4", "d5", "exd5", "Qxd5", "Nc3", "Qxg2", "Bxg2"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.PieceCount() != 28 || b.PieceCountByColor(true) != 14 || b.PieceCountByColor(false) != 14 {
+		t.Errorf("PieceCount() after four captures = %d, %d, %d, want 28, 14, 14", b.PieceCount(), b.PieceCountByColor(true), b.PieceCountByColor(false))
+	}
+}
+
+func TestFenWithTurn(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "c5", "e5", "d5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fen := b.Fen()
+	if want := "rnbqkbnr/pp2pppp/8/2ppP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3"; b.FenWithTurn(true) != want {
+		t.Errorf("FenWithTurn(true) = %s, want %s", b.FenWithTurn(true), want)
+	}
+	if want := "rnbqkbnr/pp2pppp/8/2ppP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3"; b.FenWithTurn(false) != want {
+		t.Errorf("FenWithTurn(false) = %s, want %s", b.FenWithTurn(false), want)
+	}
+	if b.Fen() != fen {
+		t.Errorf("FenWithTurn changed the board: %s", b.Fen())
+	}
+}
+
+func TestNullMove(t *testing.T) {
+	b, err := NewBoardFromMoves("e4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.MakeMove("--"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 1 2"; b.Fen() != want {
+		t.Errorf("Fen() after -- = %s, want %s", b.Fen(), want)
+	}
+	if san, white, number := b.LastMove(); san != "--" || white || number != 1 {
+		t.Errorf("LastMove() = %s, %v, %d, want --, false, 1", san, white, number)
+	}
+	if err := b.Undo(); err != nil || b.Fen() != "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1" {
+		t.Errorf("Undo() of the null move = %v, %s", err, b.Fen())
+	}
+}
+
+func TestRepetitionKey(t *testing.T) {
+	a := mustFEN(t, "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1")
+	b := mustFEN(t, "4k3/8/8/8/8/8/8/R3K3 w Q - 30 70")
+	if a.RepetitionKey() != b.RepetitionKey() {
+		t.Errorf("RepetitionKey() differs for positions that differ in the counters")
+	}
+	for _, fen := range []string{
+		"4k3/8/8/8/8/8/8/R3K3 b Q - 0 1",
+		"4k3/8/8/8/8/8/8/R3K3 w - - 0 1",
+		"4k3/8/8/8/8/8/8/R4K2 w - - 0 1",
+	} {
+		if mustFEN(t, fen).RepetitionKey() == a.RepetitionKey() {
+			t.Errorf("RepetitionKey() of %s is the same as of %s", fen, a.Fen())
+		}
+	}
+	c, err := NewBoardFromMoves("Nf3", "Nf6", "Ng1", "Ng8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.RepetitionKey() != NewBoard().RepetitionKey() {
+		t.Errorf("RepetitionKey() differs after the knights return")
+	}
+}
+
+func TestNewBoardFromArray64(t *testing.T) {
+	var squares [64]string
+	for i, p := range NewBoard().ToArray64() {
+		if p != 0 {
+			squares[i] = p.String()
+		}
+	}
+	b, err := NewBoardFromArray64(squares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Fen() != NewBoard().Fen() {
+		t.Errorf("Fen() = %s, want the initial position", b.Fen())
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify(): %v", err)
+	}
+	squares[4] = ""
+	if _, err := NewBoardFromArray64(squares); err == nil {
+		t.Errorf("NewBoardFromArray64() without a white king succeeded")
+	}
+	squares[4], squares[3] = "K", "K"
+	if _, err := NewBoardFromArray64(squares); err == nil {
+		t.Errorf("NewBoardFromArray64() with two white kings succeeded")
+	}
+	squares[3] = "X"
+	if _, err := NewBoardFromArray64(squares); err == nil {
+		t.Errorf("NewBoardFromArray64() with an invalid piece succeeded")
+	}
+}
+
+func TestFENHistory(t *testing.T) {
+	b := NewBoard()
+	start := b.Fen()
+	if fens := b.FENHistory(); !reflect.DeepEqual(fens, []string{start}) {
+		t.Errorf("FENHistory() of a new board = %v", fens)
+	}
+	var want []string
+	for _, san := range []string{"e4", "e5", "Nf3"} {
+		want = append(want, b.Fen())
+		if err := b.MakeMove(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want = append(want, b.Fen())
+	if fens := b.FENHistory(); !reflect.DeepEqual(fens, want) {
+		t.Errorf("FENHistory() = %v, want %v", fens, want)
+	}
+	if err := b.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if fens := b.FENHistory(); !reflect.DeepEqual(fens, want[:3]) {
+		t.Errorf("FENHistory() after Undo = %v, want %v", fens, want[:3])
+	}
+}
+
+func TestCanonicalKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"4k3/8/8/8/8/8/1P6/4K3 w - - 0 1", "3k4/8/8/8/8/8/6P1/3K4 w - - 0 1", true},
+		{"4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1", "4k3/4p3/8/8/8/8/4P3/4K3 b - - 0 1", true},
+		{"4k3/2p5/8/8/8/8/4P3/4K3 w - - 0 1", "3k4/3p4/8/8/8/8/5P2/3K4 b - - 0 1", true},
+		{"4k3/8/8/8/8/8/1P6/4K3 w - - 0 1", "4k3/8/8/8/8/8/2P5/4K3 w - - 0 1", false},
+		{"4k3/8/8/8/8/8/1P6/4K3 w - - 0 1", "4k3/1p6/8/8/8/8/8/4K3 b - - 0 1", false},
+		{"r3k3/8/8/8/8/8/8/4K3 b q - 0 1", "3k3r/8/8/8/8/8/8/3K4 b - - 0 1", false},
+	}
+	for _, test := range tests {
+		a, b := mustFEN(t, test.a), mustFEN(t, test.b)
+		if same := a.CanonicalKey() == b.CanonicalKey(); same != test.same {
+			t.Errorf("CanonicalKey() of %s and %s equal = %v, want %v", test.a, test.b, same, test.same)
+		}
+	}
+}
+
+func TestFenSideToMove(t *testing.T) {
+	for _, fen := range []string{
+		"rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+		"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 5",
+		"4k3/8/8/8/8/8/8/4K2R b K - 0 5",
+		"r3k3/8/8/8/8/8/8/4K3 w q - 0 5",
+	} {
+		b := mustFEN(t, fen)
+		if b.Fen() != fen {
+			t.Errorf("Fen() = %s, want %s", b.Fen(), fen)
+		}
+		if white := strings.Fields(fen)[1] == "w"; b.MoveWhite != white {
+			t.Errorf("MoveWhite of %s = %v", fen, b.MoveWhite)
+		}
+	}
+	b := mustFEN(t, "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+	if err := b.MakeMove("Nc6"); err != nil {
+		t.Fatal(err)
+	}
+	if want := "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3"; b.Fen() != want || !b.MoveWhite {
+		t.Errorf("Fen() = %s, MoveWhite %v, want %s", b.Fen(), b.MoveWhite, want)
+	}
+}
+
+func TestPieceMap(t *testing.T) {
+	pieces := NewBoard().PieceMap()
+	if len(pieces) != 32 {
+		t.Errorf("PieceMap() has %d squares, want 32", len(pieces))
+	}
+	for sq, want := range map[string]string{"a1": "R", "e1": "K", "d8": "q", "e8": "k", "h7": "p", "b2": "P"} {
+		if pieces[sq] != want {
+			t.Errorf("PieceMap()[%s] = %q, want %q", sq, pieces[sq], want)
+		}
+	}
+	if p, ok := pieces["e4"]; ok {
+		t.Errorf("PieceMap()[e4] = %q, want an empty square", p)
+	}
+}
+
+func TestMoveNumberForPly(t *testing.T) {
+	tests := []struct {
+		ply      int
+		fullmove int
+		white    bool
+	}{
+		{0, 1, true},
+		{1, 1, false},
+		{2, 2, true},
+		{5, 3, false},
+	}
+	for _, test := range tests {
+		if fullmove, white := MoveNumberForPly(test.ply); fullmove != test.fullmove || white != test.white {
+			t.Errorf("MoveNumberForPly(%d) = %d, %v, want %d, %v", test.ply, fullmove, white, test.fullmove, test.white)
+		}
+	}
+	b := NewBoard()
+	for _, san := range []string{"e4", "e5", "Nf3"} {
+		if err := b.MakeMove(san); err != nil {
+			t.Fatal(err)
+		}
+		if fullmove, white := MoveNumberForPly(b.Ply()); fullmove != int(b.MoveNumber) || white != b.MoveWhite {
+			t.Errorf("MoveNumberForPly(%d) = %d, %v, want %d, %v", b.Ply(), fullmove, white, b.MoveNumber, b.MoveWhite)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, fen := range []string{initialFEN, kiwipeteFEN, "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1"} {
+		if err := mustFEN(t, fen).Verify(); err != nil {
+			t.Errorf("Verify() of %s: %v", fen, err)
+		}
+	}
+	b := NewBoard()
+	if err := b.MakeMove("e4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(); err != nil {
+		t.Errorf("Verify() after e4: %v", err)
+	}
+	corruptions := map[string]func(b *Board){
+		"border":     func(b *Board) { b.sq[0] = 0 },
+		"king":       func(b *Board) { b.sq[b.wksq] = 0 },
+		"play":       func(b *Board) { b.play[0] = b.play[1] },
+		"en passant": func(b *Board) { b.epsq = 45 },
+	}
+	for name, corrupt := range corruptions {
+		b := NewBoard()
+		corrupt(b)
+		if err := b.Verify(); err == nil {
+			t.Errorf("Verify() with a bad %s succeeded", name)
+		}
+	}
+}
+
+func TestFENDiff(t *testing.T) {
+	b := NewBoard()
+	if err := b.MakeMove("e4"); err != nil {
+		t.Fatal(err)
+	}
+	diffs, err := FENDiff(initialFEN, b.Fen())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"e4 empty→white pawn", "e2 white pawn→empty", "side to move w→b", "en passant -→e3"}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("FENDiff() = %q, want %q", diffs, want)
+	}
+	if diffs, err := FENDiff(initialFEN, initialFEN); err != nil || len(diffs) != 0 {
+		t.Errorf("FENDiff() of the same FEN = %v, %v", diffs, err)
+	}
+	if _, err := FENDiff(initialFEN, "8/8/8/8/8/8/8/8 w - e5 0 1"); err == nil {
+		t.Errorf("FENDiff() of an invalid FEN succeeded")
+	}
+}
+
+func TestFlipTurn(t *testing.T) {
+	b := NewBoard()
+	if err := b.MakeMove("e4"); err != nil {
+		t.Fatal(err)
+	}
+	b.FlipTurn()
+	if want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2"; b.Fen() != want || !b.MoveWhite {
+		t.Errorf("Fen() = %s, MoveWhite %v, want %s", b.Fen(), b.MoveWhite, want)
+	}
+	b.FlipTurn()
+	if want := "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2"; b.Fen() != want || b.MoveWhite {
+		t.Errorf("Fen() = %s, MoveWhite %v, want %s", b.Fen(), b.MoveWhite, want)
+	}
+	if n := len(b.FENHistory()); n != 2 {
+		t.Errorf("FENHistory() has %d positions, want 2", n)
+	}
+	if err := b.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Fen() != initialFEN {
+		t.Errorf("Fen() after Undo = %s, want the initial position", b.Fen())
+	}
+}
+
+func TestPromotedSquares(t *testing.T) {
+	b := mustFEN(t, "4k3/P7/8/8/8/8/8/Q3K3 w - - 0 1")
+	if squares := b.PromotedSquares(); len(squares) != 0 {
+		t.Errorf("PromotedSquares() of a FEN = %v, want none", squares)
+	}
+	if err := b.MakeMove("a8=Q+"); err != nil {
+		t.Fatal(err)
+	}
+	if squares := b.PromotedSquares(); !reflect.DeepEqual(squares, []string{"a8"}) {
+		t.Errorf("PromotedSquares() = %v, want [a8]", squares)
+	}
+	if want := "Q3k3/8/8/8/8/8/8/Q3K3 b - - 0 1"; b.Fen() != want {
+		t.Errorf("Fen() = %s, want %s", b.Fen(), want)
+	}
+	if _, err := b.MakeMoves([]string{"Kf7", "Qa8b7+"}); err != nil {
+		t.Fatal(err)
+	}
+	if squares := b.PromotedSquares(); !reflect.DeepEqual(squares, []string{"b7"}) {
+		t.Errorf("PromotedSquares() after the queen moved = %v, want [b7]", squares)
+	}
+	if err := b.UndoN(3); err != nil {
+		t.Fatal(err)
+	}
+	if squares := b.PromotedSquares(); len(squares) != 0 {
+		t.Errorf("PromotedSquares() after Undo = %v, want none", squares)
+	}
+}
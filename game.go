@@ -0,0 +1,352 @@
+package gochess
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedVariant is returned when replaying a game
+// of a variant with rules other than the standard ones
+var ErrUnsupportedVariant = errors.New("variant is not supported")
+
+// Variant returns the chess variant of the game from the Variant tag.
+// It is Standard if the tag is missing. Only standard chess is replayed.
+// Chess960 castling is not implemented, so FinalPosition and the other
+// methods that replay the moves return ErrUnsupportedVariant for Chess960
+// games, as for any other variant, instead of misreading their castling
+func (g *Game) Variant() string {
+	if v := strings.TrimSpace(g.Tags["Variant"]); v != "" {
+		return v
+	}
+	return "Standard"
+}
+
+// Annotator returns the Annotator tag or the empty string if it is missing
+func (g *Game) Annotator() string {
+	return g.Tags["Annotator"]
+}
+
+// Source returns the Source tag or the empty string if it is missing
+func (g *Game) Source() string {
+	return g.Tags["Source"]
+}
+
+var (
+	terminations []string = []string{"normal", "time forfeit", "abandoned", "rules infraction",
+		"adjudication", "death", "emergency", "unterminated"}
+)
+
+// Termination returns the reason the game ended from the Termination tag.
+// The standard values are returned in lower case, like time forfeit, and
+// the rest unchanged. It is the empty string if the tag is missing
+func (g *Game) Termination() string {
+	tag := strings.TrimSpace(g.Tags["Termination"])
+	for _, t := range terminations {
+		if strings.EqualFold(tag, t) {
+			return t
+		}
+	}
+	return tag
+}
+
+// ValidateRoster returns the names of the tags of the seven tag roster,
+// Event, Site, Date, Round, White, Black and Result, that the game lacks
+func (g *Game) ValidateRoster() []string {
+	var missing []string
+	for _, name := range sevenTagRoster {
+		if _, ok := g.Tags[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// PlyCount returns the number of plies of the mainline, without the variations.
+// ParseMovesText must have been called before
+func (g *Game) PlyCount() int {
+	return len(g.Moves.Plies)
+}
+
+// VerifyPlyCount checks the PlyCount tag, if the game has one,
+// against the number of plies of the mainline
+func (g *Game) VerifyPlyCount() error {
+	tag, ok := g.Tags["PlyCount"]
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(tag))
+	if err != nil {
+		return fmt.Errorf("PlyCount tag %q is not a number", tag)
+	}
+	if n != g.PlyCount() {
+		return fmt.Errorf("PlyCount tag is %d but the mainline has %d plies", n, g.PlyCount())
+	}
+	return nil
+}
+
+// TruncateAt cuts the mainline after its first ply plies, removing the
+// rest together with their variations, and sets the result to *.
+// The Result and PlyCount tags, if present, are updated to match
+func (g *Game) TruncateAt(ply int) error {
+	if ply < 0 || ply > len(g.Moves.Plies) {
+		return fmt.Errorf("ply %d out of range 0-%d", ply, len(g.Moves.Plies))
+	}
+	for i := ply; i < len(g.Moves.Plies); i++ {
+		g.Moves.Plies[i] = nil
+	}
+	g.Moves.Plies = g.Moves.Plies[:ply]
+	g.Moves.Result = "*"
+	if _, ok := g.Tags["Result"]; ok {
+		g.Tags["Result"] = "*"
+	}
+	if _, ok := g.Tags["PlyCount"]; ok {
+		g.Tags["PlyCount"] = strconv.Itoa(ply)
+	}
+	return nil
+}
+
+// ValidateSetup checks the SetUp and FEN tags of a game that starts from
+// a position: SetUp must be 1 when there is a FEN tag, and only then, the
+// FEN must describe a valid position and the first move of the movetext
+// must have the move number and color of the FEN. ParseMovesText must
+// have been called before
+func (g *Game) ValidateSetup() error {
+	fen, hasFEN := g.Tags["FEN"]
+	setUp, hasSetUp := g.Tags["SetUp"]
+	if !hasFEN {
+		if hasSetUp && setUp == "1" {
+			return fmt.Errorf("SetUp tag is 1 but there is no FEN tag")
+		}
+		return nil
+	}
+	if setUp != "1" {
+		return fmt.Errorf("FEN tag requires the SetUp tag to be 1, it is %q", setUp)
+	}
+	b, err := NewBoardFromFen(fen)
+	if err != nil {
+		return fmt.Errorf("FEN tag: %s", err)
+	}
+	if err := b.Verify(); err != nil {
+		return fmt.Errorf("FEN tag: %s", err)
+	}
+	if len(g.Moves.Plies) > 0 {
+		if g.Moves.WhiteMove != b.MoveWhite {
+			return fmt.Errorf("FEN has %s to move but the moves start with %s", boolAsColor(b.MoveWhite), boolAsColor(g.Moves.WhiteMove))
+		}
+		if g.Moves.MoveNumber != b.MoveNumber {
+			return fmt.Errorf("FEN is at move %d but the moves start at move %d", b.MoveNumber, g.Moves.MoveNumber)
+		}
+	}
+	return nil
+}
+
+// startingBoard returns the board at the start of the game, the standard
+// initial position or the position of the FEN tag. It returns an error
+// for variants with rules other than the standard ones
+func (g *Game) startingBoard() (*Board, error) {
+	switch strings.ToLower(g.Variant()) {
+	case "standard", "chess", "normal", "from position":
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedVariant, g.Variant())
+	}
+	if fen, ok := g.Tags["FEN"]; ok {
+		return NewBoardFromFen(fen)
+	}
+	return NewBoard(), nil
+}
+
+// replay plays the mainline of the game calling visit, if not nil,
+// with each ply and the board after it. It returns the final board
+func (g *Game) replay(visit func(*Ply, *Board)) (*Board, error) {
+	b, err := g.startingBoard()
+	if err != nil {
+		return nil, err
+	}
+	for i, ply := range g.Moves.Plies {
+		if err := b.MakeMove(ply.SAN); err != nil {
+			return nil, fmt.Errorf("ply %d: %s", i+1, err)
+		}
+		if visit != nil {
+			visit(ply, b)
+		}
+	}
+	return b, nil
+}
+
+// FinalPosition replays the mainline of the game and returns the board
+// at its end. ParseMovesText must have been called before
+func (g *Game) FinalPosition() (*Board, error) {
+	return g.replay(nil)
+}
+
+// BoardFromPGN parses the single game of a PGN text, replays its mainline
+// and returns the final position. It is an error if the text does not
+// have exactly one game
+func BoardFromPGN(pgn string) (*Board, error) {
+	p := NewParser(strings.NewReader(pgn))
+	g, err := p.NextGame()
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, fmt.Errorf("no game found")
+	}
+	if next, err := p.NextGame(); err != nil {
+		return nil, err
+	} else if next != nil {
+		return nil, fmt.Errorf("more than one game found")
+	}
+	if err := g.ParseMovesText(); err != nil {
+		return nil, err
+	}
+	return g.FinalPosition()
+}
+
+// Positions returns an iterator over the plies of the mainline, each
+// with the board after it. The board is the same one, updated as the
+// iteration goes on, so it must not be changed or kept. The iteration
+// ends early at an illegal move. Use FENs or FinalPosition to get
+// the error
+func (g *Game) Positions() iter.Seq2[*Ply, *Board] {
+	return func(yield func(*Ply, *Board) bool) {
+		b, err := g.startingBoard()
+		if err != nil {
+			return
+		}
+		for _, ply := range g.Moves.Plies {
+			if b.MakeMove(ply.SAN) != nil || !yield(ply, b) {
+				return
+			}
+		}
+	}
+}
+
+// FENs replays the mainline of the game and returns the FEN of the
+// starting position followed by the FEN after each ply. On an illegal
+// move it returns the FENs up to it and an error with the ply number
+func (g *Game) FENs() ([]string, error) {
+	b, err := g.startingBoard()
+	if err != nil {
+		return nil, err
+	}
+	fens := []string{b.Fen()}
+	_, err = g.replay(func(ply *Ply, b *Board) {
+		fens = append(fens, b.Fen())
+	})
+	return fens, err
+}
+
+// BlunderReport is a move that Blunders flags
+type BlunderReport struct {
+	// Ply is the index of the ply in the mainline, starting from 1
+	Ply int
+	// MoveNumber and White are the move number and the color of the ply
+	MoveNumber uint8
+	White      bool
+	// SAN is the move
+	SAN string
+	// Loss is the drop of the evaluation in centipawns for the side that moved
+	Loss int
+}
+
+// Blunders replays the mainline and returns the moves after which the
+// evaluation of the position drops by more than threshold centipawns
+// for the side that moved. Positions are scored with Board.Evaluate
+// plus the best capture of the side to move by StaticExchange, so a
+// piece left en prise is a blunder. There is no search, deeper
+// tactics are not seen
+func (g *Game) Blunders(threshold int) ([]BlunderReport, error) {
+	b, err := g.startingBoard()
+	if err != nil {
+		return nil, err
+	}
+	scores := []int{b.quiescentScore()}
+	if _, err := g.replay(func(ply *Ply, b *Board) {
+		scores = append(scores, b.quiescentScore())
+	}); err != nil {
+		return nil, err
+	}
+	reports := make([]BlunderReport, 0)
+	for i, ply := range g.Moves.Plies {
+		// the score after the move is for the opponent
+		if loss := scores[i] + scores[i+1]; loss > threshold {
+			number, white := g.Moves.plyNumber(i)
+			reports = append(reports, BlunderReport{i + 1, number, white, ply.SAN, loss})
+		}
+	}
+	return reports, nil
+}
+
+// plyNumber returns the move number and color of the i-th ply of v
+func (v *Variation) plyNumber(i int) (number uint8, white bool) {
+	if !v.WhiteMove {
+		i++
+	}
+	n, white := MoveNumberForPly(i)
+	return v.MoveNumber + uint8(n-1), white
+}
+
+// Merge folds the moves of other, an analysis of the same game, into g.
+// Where the mainline of other departs from the mainline of g, its
+// continuation becomes a variation of g at that ply, and if it goes on after
+// the end of g it extends it. The variations of other are merged the same way.
+// Both games must have been parsed and start from the same position.
+// The plies of other are shared with g, not copied
+func (g *Game) Merge(other *Game) error {
+	if g.Tags["FEN"] != other.Tags["FEN"] {
+		return fmt.Errorf("games start from different positions")
+	}
+	return mergeVariation(&g.Moves, &other.Moves)
+}
+
+func mergeVariation(dst, src *Variation) error {
+	if len(dst.Plies) > 0 && len(src.Plies) > 0 &&
+		(dst.MoveNumber != src.MoveNumber || dst.WhiteMove != src.WhiteMove) {
+		return fmt.Errorf("variations start at different moves %d and %d", dst.MoveNumber, src.MoveNumber)
+	}
+	i := 0
+	for ; i < len(dst.Plies) && i < len(src.Plies) && dst.Plies[i].SAN == src.Plies[i].SAN; i++ {
+		for j := range src.Plies[i].Variations {
+			if err := addVariation(dst.Plies[i], src.Plies[i].Variations[j]); err != nil {
+				return err
+			}
+		}
+	}
+	if i == len(src.Plies) {
+		return nil
+	}
+	if i == len(dst.Plies) {
+		if len(dst.Plies) == 0 {
+			dst.MoveNumber, dst.WhiteMove = src.MoveNumber, src.WhiteMove
+		}
+		dst.Plies = append(dst.Plies, src.Plies[i:]...)
+		dst.Result = src.Result
+		return nil
+	}
+	rest := Variation{
+		Plies:  append([]*Ply(nil), src.Plies[i:]...),
+		Result: src.Result,
+	}
+	rest.MoveNumber, rest.WhiteMove = src.plyNumber(i)
+	return addVariation(dst.Plies[i], rest)
+}
+
+// addVariation adds v to the variations of ply, merging it
+// with an existing variation that starts with the same move
+func addVariation(ply *Ply, v Variation) error {
+	if len(v.Plies) == 0 {
+		return nil
+	}
+	for j := range ply.Variations {
+		if w := &ply.Variations[j]; len(w.Plies) > 0 && w.Plies[0].SAN == v.Plies[0].SAN {
+			return mergeVariation(w, &v)
+		}
+	}
+	if ply.SAN != v.Plies[0].SAN {
+		ply.Variations = append(ply.Variations, v)
+	}
+	return nil
+}
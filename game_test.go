@@ -0,0 +1,271 @@
+package gochess
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariant(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[SetUp \"1\"]\n[FEN \"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1\"]\n\n1. e4 Kd7 *\n")
+	if v := game.Variant(); v != "Standard" {
+		t.Errorf("Variant() = %q, want Standard", v)
+	}
+	b, err := game.FinalPosition()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "8/3k4/8/8/4P3/8/8/4K3 w - - 1 2"; b.Fen() != want {
+		t.Errorf("FinalPosition() = %s, want %s", b.Fen(), want)
+	}
+
+	game = mustParseGame(t, "[Event \"x\"]\n[Variant \"Chess960\"]\n[SetUp \"1\"]\n[FEN \"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9\"]\n\n9. g3 *\n")
+	if v := game.Variant(); v != "Chess960" {
+		t.Errorf("Variant() = %q, want Chess960", v)
+	}
+	if _, err := game.FinalPosition(); !errors.Is(err, ErrUnsupportedVariant) {
+		t.Errorf("FinalPosition() of a Chess960 game = %v, want ErrUnsupportedVariant", err)
+	}
+}
+
+const ruyLopez = "1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 Nf6 5. O-O Be7 6. Re1 b5 7. Bb3 d6 8. c3 O-O 9. h3 Nb8 "
+
+func TestMerge(t *testing.T) {
+	game := mustParseMoves(t, ruyLopez+"10. d4 Nbd7 *")
+	other := mustParseMoves(t, ruyLopez+"10. d3 c5 11. Nbd2 *")
+	if err := game.Merge(other); err != nil {
+		t.Fatal(err)
+	}
+	plies := game.Moves.Plies
+	if len(plies) != 20 || plies[18].SAN != "d4" {
+		t.Fatalf("mainline has %d plies, ply 19 %s, want 20 and d4", len(plies), plies[18].SAN)
+	}
+	if len(plies[18].Variations) != 1 {
+		t.Fatalf("d4 has %d variations, want 1", len(plies[18].Variations))
+	}
+	v := plies[18].Variations[0]
+	if v.MoveNumber != 10 || !v.WhiteMove || len(v.Plies) != 3 || v.Plies[0].SAN != "d3" {
+		t.Errorf("variation = %s, starting at %d white %v", v.MoveText(), v.MoveNumber, v.WhiteMove)
+	}
+	if err := game.Merge(mustParseMoves(t, ruyLopez+"10. d3 c5 11. Nbd2 Nc6 *")); err != nil {
+		t.Fatal(err)
+	}
+	if v := plies[18].Variations; len(v) != 1 || len(v[0].Plies) != 4 {
+		t.Errorf("merging a longer line added a variation or did not extend it: %v", v)
+	}
+	other = mustParseMoves(t, "1. d4 *")
+	other.Tags["FEN"] = "4k3/8/8/8/8/8/8/4K3 w - - 0 1"
+	if err := game.Merge(other); err == nil {
+		t.Errorf("merging a game from another position succeeded")
+	}
+}
+
+func TestFENs(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 2. Nf3 Nc6 3. Bb5 *")
+	fens, err := game.FENs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fens) != 6 {
+		t.Fatalf("FENs() returned %d FENs, want 6", len(fens))
+	}
+	if fens[0] != NewBoard().Fen() {
+		t.Errorf("first FEN = %s, want the initial position", fens[0])
+	}
+	if want := "r1bqkbnr/pppp1ppp/2n5/1B2p3/4P3/5N2/PPPP1PPP/RNBQK2R b KQkq - 3 3"; fens[5] != want {
+		t.Errorf("last FEN = %s, want %s", fens[5], want)
+	}
+	game = mustParseMoves(t, "1. e4 e5 2. Ke3 *")
+	fens, err = game.FENs()
+	if err == nil || len(fens) != 3 || !strings.Contains(err.Error(), "3") {
+		t.Errorf("FENs() with an illegal third ply = %d FENs, %v", len(fens), err)
+	}
+}
+
+func TestValidateRoster(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[Date \"2024.01.01\"]\n[White \"a\"]\n[Black \"b\"]\n[Result \"*\"]\n\n1. e4 *\n")
+	if missing := game.ValidateRoster(); !reflect.DeepEqual(missing, []string{"Site", "Round"}) {
+		t.Errorf("ValidateRoster() = %v, want [Site Round]", missing)
+	}
+	game.Tags["Site"], game.Tags["Round"] = "?", "1"
+	if missing := game.ValidateRoster(); missing != nil {
+		t.Errorf("ValidateRoster() of a complete roster = %v, want nil", missing)
+	}
+}
+
+func TestPlyCount(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[PlyCount \"5\"]\n\n1. e4 e5 (1... c5 2. Nf3) 2. Nf3 Nc6 3. Bb5 *\n")
+	if n := game.PlyCount(); n != 5 {
+		t.Errorf("PlyCount() = %d, want 5", n)
+	}
+	if err := game.VerifyPlyCount(); err != nil {
+		t.Errorf("VerifyPlyCount(): %v", err)
+	}
+	game.Tags["PlyCount"] = "6"
+	if err := game.VerifyPlyCount(); err == nil {
+		t.Errorf("VerifyPlyCount() with a wrong tag succeeded")
+	}
+	game.Tags["PlyCount"] = "five"
+	if err := game.VerifyPlyCount(); err == nil {
+		t.Errorf("VerifyPlyCount() with an invalid tag succeeded")
+	}
+	delete(game.Tags, "PlyCount")
+	if err := game.VerifyPlyCount(); err != nil {
+		t.Errorf("VerifyPlyCount() without the tag: %v", err)
+	}
+}
+
+func TestAnnotatorAndSource(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[Annotator \"Kasparov\"]\n[Source \"ChessBase\"]\n\n1. e4 *\n")
+	if game.Annotator() != "Kasparov" || game.Source() != "ChessBase" {
+		t.Errorf("Annotator(), Source() = %q, %q", game.Annotator(), game.Source())
+	}
+	game = mustParseMoves(t, "1. e4 *")
+	if game.Annotator() != "" || game.Source() != "" {
+		t.Errorf("Annotator(), Source() without the tags = %q, %q", game.Annotator(), game.Source())
+	}
+}
+
+func TestTruncateAt(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[Result \"1-0\"]\n[PlyCount \"9\"]\n\n"+
+		"1. e4 e5 2. Nf3 Nc6 3. Bb5 a6 4. Ba4 (4. Bxc6 dxc6) Nf6 5. O-O 1-0\n")
+	if err := game.TruncateAt(6); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(game.Moves.Plies); n != 6 {
+		t.Errorf("mainline has %d plies, want 6", n)
+	}
+	if game.Moves.Result != "*" || game.Tags["Result"] != "*" || game.Tags["PlyCount"] != "6" {
+		t.Errorf("result %q, tags %v", game.Moves.Result, game.Tags)
+	}
+	if err := game.TruncateAt(7); err == nil {
+		t.Errorf("TruncateAt past the end succeeded")
+	}
+	if err := game.TruncateAt(-1); err == nil {
+		t.Errorf("TruncateAt(-1) succeeded")
+	}
+	if err := game.TruncateAt(0); err != nil || len(game.Moves.Plies) != 0 {
+		t.Errorf("TruncateAt(0) = %v with %d plies", err, len(game.Moves.Plies))
+	}
+}
+
+func TestTermination(t *testing.T) {
+	tests := map[string]string{
+		"Time forfeit":  "time forfeit",
+		"NORMAL":        "normal",
+		" abandoned ":   "abandoned",
+		"Won by resign": "Won by resign",
+	}
+	for tag, want := range tests {
+		game := &Game{Tags: map[string]string{"Termination": tag}}
+		if s := game.Termination(); s != want {
+			t.Errorf("Termination() of %q = %q, want %q", tag, s, want)
+		}
+	}
+	if s := (&Game{Tags: map[string]string{}}).Termination(); s != "" {
+		t.Errorf("Termination() without the tag = %q", s)
+	}
+}
+
+func TestBlunders(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 2. Qh5 Nc6 3. Qxe5 Nxe5 *")
+	reports, err := game.Blunders(300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Blunders() = %v, want only Qxe5", reports)
+	}
+	r := reports[0]
+	if r.Ply != 5 || r.MoveNumber != 3 || !r.White || r.SAN != "Qxe5" || r.Loss < 500 {
+		t.Errorf("Blunders() = %+v, want Qxe5 at ply 5 losing the queen", r)
+	}
+	if reports, err := mustParseMoves(t, ruyLopez+"*").Blunders(300); err != nil || len(reports) != 0 {
+		t.Errorf("Blunders() of the Ruy Lopez = %v, %v", reports, err)
+	}
+	if _, err := mustParseMoves(t, "1. e4 Ke2 *").Blunders(300); err == nil {
+		t.Errorf("Blunders() of a game with an illegal move succeeded")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 2. Nf3 *")
+	fens, err := game.FENs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sans, got []string
+	for ply, b := range game.Positions() {
+		sans = append(sans, ply.SAN)
+		got = append(got, b.Fen())
+	}
+	if want := []string{"e4", "e5", "Nf3"}; !reflect.DeepEqual(sans, want) {
+		t.Errorf("plies = %v, want %v", sans, want)
+	}
+	if !reflect.DeepEqual(got, fens[1:]) {
+		t.Errorf("positions = %v, want %v", got, fens[1:])
+	}
+	n := 0
+	for range game.Positions() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("iteration went on after break")
+	}
+	n = 0
+	for range mustParseMoves(t, "1. e4 Ke2 2. Nf3 *").Positions() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("iteration yielded %d positions for a game with an illegal second move", n)
+	}
+}
+
+func TestBoardFromPGN(t *testing.T) {
+	b, err := BoardFromPGN("[Event \"a\"]\n\n1. e4 e5 2. Nf3 *\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NewBoard()
+	if _, err := want.MakeMoves([]string{"e4", "e5", "Nf3"}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Fen() != want.Fen() {
+		t.Errorf("BoardFromPGN() = %s, want %s", b.Fen(), want.Fen())
+	}
+	for _, pgn := range []string{
+		"",
+		"[Event \"a\"]\n\n1. e4 *\n\n[Event \"b\"]\n\n1. d4 *\n",
+		"[Event \"a\"]\n\n1. e4 Ke2 *\n",
+	} {
+		if _, err := BoardFromPGN(pgn); err == nil {
+			t.Errorf("BoardFromPGN(%q) succeeded", pgn)
+		}
+	}
+}
+
+func TestValidateSetup(t *testing.T) {
+	const afterE4 = "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"
+	tests := []struct {
+		tags, moves string
+		valid       bool
+	}{
+		{"", "1. e4 *", true},
+		{"[SetUp \"0\"]\n", "1. e4 *", true},
+		{"[SetUp \"1\"]\n", "1. e4 *", false},
+		{"[FEN \"" + afterE4 + "\"]\n", "1... e5 *", false},
+		{"[SetUp \"1\"]\n[FEN \"" + afterE4 + "\"]\n", "1... e5 *", true},
+		{"[SetUp \"1\"]\n[FEN \"" + afterE4 + "\"]\n", "*", true},
+		{"[SetUp \"1\"]\n[FEN \"" + afterE4 + "\"]\n", "1. d4 *", false},
+		{"[SetUp \"1\"]\n[FEN \"" + afterE4 + "\"]\n", "2... e5 *", false},
+		{"[SetUp \"1\"]\n[FEN \"8/8/8/8/8/8/8/4K3 w - - 0 1\"]\n", "*", false},
+	}
+	for _, test := range tests {
+		game := mustParseGame(t, "[Event \"a\"]\n"+test.tags+"\n"+test.moves+"\n")
+		if err := game.ValidateSetup(); (err == nil) != test.valid {
+			t.Errorf("ValidateSetup() of %q %q: %v, want valid %v", test.tags, test.moves, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/anastasop/gochess
+
+go 1.23
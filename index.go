@@ -0,0 +1,65 @@
+package gochess
+
+import (
+	"fmt"
+	"io"
+)
+
+// GameRef is an occurrence of a position in a PGN database
+type GameRef struct {
+	// Game is the index of the game in the database, starting from 0
+	Game int
+	// Ply is the number of mainline plies played to reach the position.
+	// It is 0 for the starting position
+	Ply int
+}
+
+// PositionIndex maps positions to the games that reach them
+type PositionIndex struct {
+	refs map[uint64][]GameRef
+}
+
+// BuildPositionIndex parses all the games of a PGN database and replays
+// their mainlines recording where each position occurs. Positions are
+// compared with RepetitionKey so transpositions are found. It returns
+// an error for the first game that cannot be parsed or replayed
+func BuildPositionIndex(r io.Reader) (*PositionIndex, error) {
+	idx := &PositionIndex{refs: make(map[uint64][]GameRef)}
+	p := NewParser(r)
+	for n := 0; ; n++ {
+		game, err := p.NextGame()
+		if err != nil {
+			return nil, err
+		}
+		if game == nil {
+			break
+		}
+		if err := game.ParseMovesText(); err != nil {
+			return nil, fmt.Errorf("game %d: %s", n+1, err)
+		}
+		b, err := game.startingBoard()
+		if err != nil {
+			return nil, fmt.Errorf("game %d: %s", n+1, err)
+		}
+		idx.add(b, GameRef{n, 0})
+		i := 0
+		if _, err := game.replay(func(ply *Ply, b *Board) {
+			i++
+			idx.add(b, GameRef{n, i})
+		}); err != nil {
+			return nil, fmt.Errorf("game %d: %s", n+1, err)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *PositionIndex) add(b *Board, ref GameRef) {
+	key := b.RepetitionKey()
+	idx.refs[key] = append(idx.refs[key], ref)
+}
+
+// Find returns the occurrences of the position of the board in the
+// indexed games, in the order of the games
+func (idx *PositionIndex) Find(b *Board) []GameRef {
+	return idx.refs[b.RepetitionKey()]
+}
@@ -0,0 +1,38 @@
+package gochess
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPositionIndex(t *testing.T) {
+	pgn := "[Event \"French\"]\n\n1. e4 e6 2. d4 d5 *\n\n[Event \"Transposed\"]\n\n1. d4 e6 2. e4 d5 3. Nc3 *\n"
+	idx, err := BuildPositionIndex(strings.NewReader(pgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewBoardFromMoves("e4", "e6", "d4", "d5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := idx.Find(b); !reflect.DeepEqual(refs, []GameRef{{0, 4}, {1, 4}}) {
+		t.Errorf("Find() of the French = %v, want [{0 4} {1 4}]", refs)
+	}
+	if refs := idx.Find(NewBoard()); !reflect.DeepEqual(refs, []GameRef{{0, 0}, {1, 0}}) {
+		t.Errorf("Find() of the initial position = %v", refs)
+	}
+	b, err = NewBoardFromMoves("e4", "e6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := idx.Find(b); !reflect.DeepEqual(refs, []GameRef{{0, 2}}) {
+		t.Errorf("Find() after 1.e4 e6 = %v, want [{0 2}]", refs)
+	}
+	if refs := idx.Find(mustFEN(t, "4k3/8/8/8/8/8/8/4K3 w - - 0 1")); refs != nil {
+		t.Errorf("Find() of a position not in the games = %v", refs)
+	}
+	if _, err := BuildPositionIndex(strings.NewReader("[Event \"bad\"]\n\n1. e5 *\n")); err == nil {
+		t.Errorf("BuildPositionIndex() of a game with an illegal move succeeded")
+	}
+}
@@ -0,0 +1,898 @@
+package gochess
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+var (
+	promotionTypes [4]uint8 = [4]uint8{pQUEEN, pROOK, pBISHOP, pKNIGHT}
+
+	pieceNames [7]string = [7]string{"", "pawn", "knight", "bishop", "rook", "queen", "king"}
+)
+
+// move is a move of a piece from one square to another.
+// Castling is represented as the two squares move of the king
+// and promotes is the type of the piece a pawn promotes to or 0
+type move struct {
+	from     int8
+	to       int8
+	promotes uint8
+}
+
+func (b *Board) isCastling(m move) bool {
+	_, typ := b.sq[m.from].identify()
+	return typ == pKING && (m.to-m.from == 2 || m.from-m.to == 2)
+}
+
+func (b *Board) isCapture(m move) bool {
+	if _, typ := b.sq[m.from].identify(); typ == pPAWN {
+		return m.from%10 != m.to%10
+	}
+	return b.sq[m.to] != 0
+}
+
+// targets returns the squares the piece on from may move to
+// following its movement pattern. Not all of them are legal moves
+func (b *Board) targets(from int8) []int8 {
+	col, typ := b.sq[from].identify()
+	s := make([]int8, 0, 28)
+	var dirs []int8
+	switch typ {
+	case pPAWN:
+		step := int8(10)
+		if col == cBLACK {
+			step = -10
+		}
+		return append(s, from+step, from+2*step, from+step-1, from+step+1)
+	case pKNIGHT:
+		for _, d := range dKNIGHT {
+			s = append(s, from+d)
+		}
+		return s
+	case pKING:
+		for _, d := range dKING {
+			s = append(s, from+d)
+		}
+		return s
+	case pBISHOP:
+		dirs = dDIAGONAL[:]
+	case pROOK:
+		dirs = dSTRAIGHT[:]
+	case pQUEEN:
+		dirs = dKING[:]
+	}
+	for _, d := range dirs {
+		for to := from + d; b.sq[to] != 0xff; to += d {
+			s = append(s, to)
+			if b.sq[to] != 0 {
+				break
+			}
+		}
+	}
+	return s
+}
+
+// castlingAllowed reports whether col can castle now to the given side
+func (b *Board) castlingAllowed(col color, kingside bool) bool {
+	return b.castlingProblem(col, kingside) == ""
+}
+
+// castlingProblem returns why col cannot castle now to the given side
+// or the empty string if it can. The king and the rook must not have moved,
+// the squares between them must be empty and the king must not be in check,
+// pass through or land on attacked squares
+func (b *Board) castlingProblem(col color, kingside bool) string {
+	home := int8(21)
+	if col == cBLACK {
+		home = 91
+	}
+	king, rook, dir := home+4, home+7, int8(1)
+	if !kingside {
+		rook, dir = home, -1
+	}
+	if b.sq[king] != newPiece(col, pKING, false) || b.sq[rook] != newPiece(col, pROOK, false) {
+		return "the king or the rook has moved"
+	}
+	for sq := king + dir; sq != rook; sq += dir {
+		if b.sq[sq] != 0 {
+			return "there are pieces between the king and the rook"
+		}
+	}
+	if b.isAttacked(king, col.opposite()) {
+		return "the king is in check"
+	}
+	for sq := king + dir; sq != king+3*dir; sq += dir {
+		if b.isAttacked(sq, col.opposite()) {
+			return "the king passes through or lands on an attacked square"
+		}
+	}
+	return ""
+}
+
+// legalMoves returns all the legal moves of the side to move
+func (b *Board) legalMoves() []move {
+	col := b.activeMove
+	moves := make([]move, 0, 48)
+	for from := int8(21); from < 99; from++ {
+		if p := b.sq[from]; p == 0 || p == 0xff {
+			continue
+		}
+		c, typ := b.sq[from].identify()
+		if c != col {
+			continue
+		}
+		for _, to := range b.targets(from) {
+			if !b.isLegal(from, to) {
+				continue
+			}
+			if typ == pPAWN && (to/10 == 9 || to/10 == 2) {
+				for _, promotes := range promotionTypes {
+					moves = append(moves, move{from, to, promotes})
+				}
+			} else {
+				moves = append(moves, move{from, to, 0})
+			}
+		}
+	}
+	ksq := b.wksq
+	if col == cBLACK {
+		ksq = b.bksq
+	}
+	if b.castlingAllowed(col, true) {
+		moves = append(moves, move{ksq, ksq + 2, 0})
+	}
+	if b.castlingAllowed(col, false) {
+		moves = append(moves, move{ksq, ksq - 2, 0})
+	}
+	return moves
+}
+
+// lan returns the move in long algebraic notation
+func (b *Board) lan(m move) string {
+	if b.isCastling(m) {
+		if m.to > m.from {
+			return "O-O"
+		}
+		return "O-O-O"
+	}
+	s := ""
+	if _, typ := b.sq[m.from].identify(); typ != pPAWN {
+		s = string("PNBRQK"[typ-1])
+	}
+	sep := "-"
+	if b.isCapture(m) {
+		sep = "x"
+	}
+	s += sq2string(m.from) + sep + sq2string(m.to)
+	if m.promotes != 0 {
+		s += "=" + string("PNBRQK"[m.promotes-1])
+	}
+	return s
+}
+
+// LANMoves returns the legal moves of the side to move in long
+// algebraic notation like e2-e4, Ng1-f3, Nf3xe5, e7-e8=Q and O-O
+func (b *Board) LANMoves() []string {
+	moves := b.legalMoves()
+	s := make([]string, len(moves))
+	for i, m := range moves {
+		s[i] = b.lan(m)
+	}
+	return s
+}
+
+// CastlingTargets returns the squares the king of the side to move
+// reaches with the castling moves that are legal now, g1 and c1 for
+// white or g8 and c8 for black
+func (b *Board) CastlingTargets() []string {
+	s := make([]string, 0, 2)
+	rank := "1"
+	if b.activeMove == cBLACK {
+		rank = "8"
+	}
+	if b.castlingAllowed(b.activeMove, true) {
+		s = append(s, "g"+rank)
+	}
+	if b.castlingAllowed(b.activeMove, false) {
+		s = append(s, "c"+rank)
+	}
+	return s
+}
+
+// CountLegalMoves returns the number of legal moves of the side to move.
+// It is cheaper than counting the moves in SAN as no notation is built
+// and no move list is allocated. Each move is made and taken back in place
+func (b *Board) CountLegalMoves() int {
+	col := b.activeMove
+	n := 0
+	for from := int8(21); from < 99; from++ {
+		if p := b.sq[from]; p == 0 || p == 0xff {
+			continue
+		}
+		c, typ := b.sq[from].identify()
+		if c != col {
+			continue
+		}
+		var dirs []int8
+		switch typ {
+		case pPAWN:
+			n += b.countPawnMoves(from, col)
+			continue
+		case pKNIGHT:
+			for _, d := range dKNIGHT {
+				n += b.countMove(from, from+d, col)
+			}
+			continue
+		case pKING:
+			for _, d := range dKING {
+				n += b.countMove(from, from+d, col)
+			}
+			continue
+		case pBISHOP:
+			dirs = dDIAGONAL[:]
+		case pROOK:
+			dirs = dSTRAIGHT[:]
+		case pQUEEN:
+			dirs = dKING[:]
+		}
+		for _, d := range dirs {
+			for to := from + d; b.sq[to] != 0xff; to += d {
+				n += b.countMove(from, to, col)
+				if b.sq[to] != 0 {
+					break
+				}
+			}
+		}
+	}
+	if b.castlingAllowed(col, true) {
+		n++
+	}
+	if b.castlingAllowed(col, false) {
+		n++
+	}
+	return n
+}
+
+// countPawnMoves counts the legal moves of the pawn of col on from.
+// Promotions count once for each piece the pawn may become
+func (b *Board) countPawnMoves(from int8, col color) int {
+	step, home := int8(10), int8(3)
+	if col == cBLACK {
+		step, home = -10, 8
+	}
+	moves := 1
+	if to := from + step; to/10 == 9 || to/10 == 2 {
+		moves = len(promotionTypes)
+	}
+	n := 0
+	if to := from + step; b.sq[to] == 0 {
+		n += moves * b.countMove(from, to, col)
+		if to += step; from/10 == home && b.sq[to] == 0 {
+			n += b.countMove(from, to, col)
+		}
+	}
+	for _, to := range [2]int8{from + step - 1, from + step + 1} {
+		if b.sq[to] != 0 && b.sq[to] != 0xff {
+			n += moves * b.countMove(from, to, col)
+		} else if b.epsq != 0 && to == b.epsq {
+			// the captured pawn leaves the board too
+			pawn := b.sq[to-step]
+			b.sq[to-step] = 0
+			n += b.countMove(from, to, col)
+			b.sq[to-step] = pawn
+		}
+	}
+	return n
+}
+
+// countMove returns 1 if the piece of col on from can move to the
+// empty or enemy occupied square to without leaving its king in check
+// and 0 otherwise. The board is restored before it returns
+func (b *Board) countMove(from, to int8, col color) int {
+	target := b.sq[to]
+	if target == 0xff {
+		return 0
+	}
+	if c, _ := target.identify(); target != 0 && c == col {
+		return 0
+	}
+	ksq := b.wksq
+	if col == cBLACK {
+		ksq = b.bksq
+	}
+	if ksq == from {
+		ksq = to
+	}
+	b.sq[to], b.sq[from] = b.sq[from], 0
+	attacked := b.isAttacked(ksq, col.opposite())
+	b.sq[from], b.sq[to] = b.sq[to], target
+	if attacked {
+		return 0
+	}
+	return 1
+}
+
+// QuietMoves returns the legal moves of the side to move in SAN that
+// neither capture nor promote. Castling is a quiet move
+func (b *Board) QuietMoves() []string {
+	s := make([]string, 0)
+	for _, m := range b.legalMoves() {
+		if !b.isCapture(m) && m.promotes == 0 {
+			s = append(s, b.san(m))
+		}
+	}
+	return s
+}
+
+// PromotionMoves returns the legal promotions of the side to move in SAN,
+// one for each piece a pawn can promote to, with or without capture
+func (b *Board) PromotionMoves() []string {
+	s := make([]string, 0)
+	for _, m := range b.legalMoves() {
+		if m.promotes != 0 {
+			s = append(s, b.san(m))
+		}
+	}
+	return s
+}
+
+// CheckingPieces returns the squares of the pieces that give check
+// to the king of the side to move. It is empty if there is no check
+// and it has two squares for a double check
+func (b *Board) CheckingPieces() []string {
+	ksq := b.wksq
+	if b.activeMove == cBLACK {
+		ksq = b.bksq
+	}
+	s := make([]string, 0, 2)
+	for _, sq := range b.attackersOf(ksq, b.activeMove.opposite()) {
+		s = append(s, sq2string(sq))
+	}
+	return s
+}
+
+// MoveFlags describe special kinds of moves
+type MoveFlags uint8
+
+const (
+	// FlagCapture is set when the move captures a piece
+	FlagCapture MoveFlags = 1 << iota
+	// FlagEnPassant is set when a pawn captures en passant
+	FlagEnPassant
+	// FlagCastling is set for castling moves. From and To are the king squares
+	FlagCastling
+	// FlagPromotion is set when a pawn promotes
+	FlagPromotion
+)
+
+// Move is a move on the board described by its squares and pieces
+type Move struct {
+	// From is the square the piece moves from like g1
+	From string
+	// To is the square the piece moves to like f3
+	To string
+	// Piece is the type of the moving piece, one of PNBRQK
+	Piece string
+	// Captured is the type of the captured piece or empty
+	Captured string
+	// Promotion is the type of the piece a pawn promotes to or empty
+	Promotion string
+	// Flags are the special kinds of the move
+	Flags MoveFlags
+}
+
+func (b *Board) exportMove(m move) Move {
+	_, typ := b.sq[m.from].identify()
+	mv := Move{
+		From:  sq2string(m.from),
+		To:    sq2string(m.to),
+		Piece: string("PNBRQK"[typ-1]),
+	}
+	if b.isCastling(m) {
+		mv.Flags |= FlagCastling
+	} else if b.isCapture(m) {
+		mv.Flags |= FlagCapture
+		mv.Captured = "P"
+		if b.sq[m.to] == 0 {
+			mv.Flags |= FlagEnPassant
+		} else {
+			_, t := b.sq[m.to].identify()
+			mv.Captured = string("PNBRQK"[t-1])
+		}
+	}
+	if m.promotes != 0 {
+		mv.Flags |= FlagPromotion
+		mv.Promotion = string("PNBRQK"[m.promotes-1])
+	}
+	return mv
+}
+
+// GenerateMoves returns the legal moves of the side to move
+func (b *Board) GenerateMoves() []Move {
+	moves := b.legalMoves()
+	s := make([]Move, len(moves))
+	for i, m := range moves {
+		s[i] = b.exportMove(m)
+	}
+	return s
+}
+
+// Perft counts the positions reached after all the legal move
+// sequences of depth plies. It is used to validate move generation
+func (b *Board) Perft(depth int) uint64 {
+	if depth <= 0 {
+		return 1
+	}
+	moves := b.legalMoves()
+	if depth == 1 {
+		return uint64(len(moves))
+	}
+	var n uint64
+	for _, m := range moves {
+		c := b.clone()
+		c.makeMove(m, "")
+		n += c.Perft(depth - 1)
+	}
+	return n
+}
+
+// PerftParallel is like Perft but splits the moves at the root
+// among workers goroutines, each playing on its own copy of the board
+func (b *Board) PerftParallel(depth, workers int) uint64 {
+	if depth <= 1 || workers <= 1 {
+		return b.Perft(depth)
+	}
+	moves := b.legalMoves()
+	jobs := make(chan move, len(moves))
+	for _, m := range moves {
+		jobs <- m
+	}
+	close(jobs)
+	results := make(chan uint64, workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			var n uint64
+			for m := range jobs {
+				c := b.clone()
+				c.makeMove(m, "")
+				n += c.Perft(depth - 1)
+			}
+			results <- n
+		}()
+	}
+	var total uint64
+	for i := 0; i < workers; i++ {
+		total += <-results
+	}
+	return total
+}
+
+// ExplainIllegal returns a human readable reason why san cannot be
+// played in the position, like "leaves the king in check" or
+// "ambiguous". It returns the empty string if the move is legal,
+// that is if MakeMove would play it
+func (b *Board) ExplainIllegal(san string) string {
+	col := b.activeMove
+	_, err := b.resolveSAN(san, col)
+	if err == nil {
+		return ""
+	}
+	if strings.HasPrefix(san, "O-O") {
+		return b.castlingProblem(col, !strings.HasPrefix(san, "O-O-O"))
+	}
+	matches := rSANRE.FindStringSubmatch(san)
+	if matches == nil {
+		return "not a valid SAN move"
+	}
+	piece, fromHint, dsq, promotes := matches[1], matches[2], matches[3], matches[4]
+	if piece == "" {
+		piece = "P"
+		if fromHint == "" {
+			fromHint = dsq[:1]
+		}
+	}
+	typ := uint8(strings.Index("PNBRQK", piece) + 1)
+	to := string2sq(dsq)
+	if b.sq[to] != 0 {
+		if c, _ := b.sq[to].identify(); c == col {
+			return "the square is occupied by a piece of the same color"
+		}
+	}
+	reaching, legal := 0, 0
+	for from := int8(21); from < 99; from++ {
+		if c, t := b.sq[from].identify(); b.sq[from] == 0 || b.sq[from] == 0xff || c != col || t != typ {
+			continue
+		}
+		if !strings.Contains(sq2string(from), fromHint) || !b.reaches(from, to) {
+			continue
+		}
+		reaching++
+		if b.tryMove(true, col, from, to, promotes) == nil {
+			legal++
+		}
+	}
+	switch {
+	case reaching == 0:
+		return "no such piece can reach the square"
+	case legal == 0:
+		return "leaves the king in check"
+	case legal > 1:
+		return "ambiguous"
+	}
+	if lastRank := to/10 == 9 || to/10 == 2; typ == pPAWN && lastRank != (promotes != "") {
+		if lastRank {
+			return "the pawn must promote"
+		}
+		return "only pawns on the last rank promote"
+	}
+	return err.Error()
+}
+
+// inCheck reports whether the king of the side to move is attacked
+func (b *Board) inCheck() bool {
+	ksq := b.wksq
+	if b.activeMove == cBLACK {
+		ksq = b.bksq
+	}
+	return b.isAttacked(ksq, b.activeMove.opposite())
+}
+
+// san returns the legal move m in standard algebraic notation,
+// with the check and checkmate suffixes
+func (b *Board) san(m move) string {
+	if m.from == 0 {
+		return "--"
+	}
+	s := ""
+	if b.isCastling(m) {
+		s = "O-O"
+		if m.to < m.from {
+			s = "O-O-O"
+		}
+	} else {
+		if _, typ := b.sq[m.from].identify(); typ != pPAWN {
+			s = string("PNBRQK"[typ-1])
+		}
+		s += b.disambiguation(m.from, m.to)
+		if b.isCapture(m) {
+			s += "x"
+		}
+		s += sq2string(m.to)
+		if m.promotes != 0 {
+			s += "=" + string("PNBRQK"[m.promotes-1])
+		}
+	}
+	after := b.clone()
+	after.makeMove(m, s)
+	if after.inCheck() {
+		if len(after.legalMoves()) == 0 {
+			return s + "#"
+		}
+		return s + "+"
+	}
+	return s
+}
+
+// DescribeMove returns a sentence in English that describes the move san
+// of the side to move, like "White knight from g1 captures the pawn on f3,
+// giving check". It is useful for screen readers and commentary.
+// The board does not change. It is an error if the move is not legal
+func (b *Board) DescribeMove(san string) (string, error) {
+	m, err := b.resolveSAN(san, b.activeMove)
+	if err != nil {
+		return "", err
+	}
+	side := "White"
+	if b.activeMove == cBLACK {
+		side = "Black"
+	}
+	var s string
+	_, typ := b.sq[m.from].identify()
+	switch {
+	case m.from == 0:
+		return side + " passes", nil
+	case b.isCastling(m) && m.to > m.from:
+		s = side + " castles kingside"
+	case b.isCastling(m):
+		s = side + " castles queenside"
+	case b.isCapture(m) && b.sq[m.to] == 0:
+		s = fmt.Sprintf("%s pawn from %s captures the pawn en passant on %s", side, sq2string(m.from), sq2string(m.to))
+	case b.isCapture(m):
+		_, captured := b.sq[m.to].identify()
+		s = fmt.Sprintf("%s %s from %s captures the %s on %s", side, pieceNames[typ], sq2string(m.from), pieceNames[captured], sq2string(m.to))
+	default:
+		s = fmt.Sprintf("%s %s from %s moves to %s", side, pieceNames[typ], sq2string(m.from), sq2string(m.to))
+	}
+	if m.promotes != 0 {
+		s += " and promotes to a " + pieceNames[m.promotes]
+	}
+	after := b.clone()
+	after.makeMove(m, san)
+	switch after.Status() {
+	case "check":
+		s += ", giving check"
+	case "checkmate":
+		s += ", giving checkmate"
+	case "stalemate":
+		s += ", giving stalemate"
+	}
+	return s, nil
+}
+
+// MoveIsCapture reports whether the move san of the side to move
+// captures a piece, en passant included. The board does not change
+func (b *Board) MoveIsCapture(san string) (bool, error) {
+	m, err := b.resolveSAN(san, b.activeMove)
+	if err != nil {
+		return false, err
+	}
+	return m.from != 0 && b.isCapture(m) && !b.isCastling(m), nil
+}
+
+// MoveIsCastle reports whether the move san of the side to move
+// is castling. The board does not change
+func (b *Board) MoveIsCastle(san string) (bool, error) {
+	m, err := b.resolveSAN(san, b.activeMove)
+	if err != nil {
+		return false, err
+	}
+	return m.from != 0 && b.isCastling(m), nil
+}
+
+// uci returns the move m in UCI coordinate notation like e2e4 or e7e8q
+func uci(m move) string {
+	if m.from == 0 {
+		return "0000"
+	}
+	s := sq2string(m.from) + sq2string(m.to)
+	if m.promotes != 0 {
+		s += string("pnbrqk"[m.promotes-1])
+	}
+	return s
+}
+
+// AnnotatedMove is a legal move in SAN and UCI notation
+// together with the position after it
+type AnnotatedMove struct {
+	SAN string
+	UCI string
+	// FEN is the position after the move
+	FEN string
+}
+
+// AnnotatedLegalMoves returns the legal moves of the side to move, each
+// with its SAN, its UCI notation and the FEN of the position after it
+func (b *Board) AnnotatedLegalMoves() []AnnotatedMove {
+	moves := b.legalMoves()
+	s := make([]AnnotatedMove, len(moves))
+	for i, m := range moves {
+		san := b.san(m)
+		after := b.clone()
+		after.makeMove(m, san)
+		s[i] = AnnotatedMove{san, uci(m), after.Fen()}
+	}
+	return s
+}
+
+// resolveUCI finds the legal move for a move in UCI coordinate
+// notation like e2e4 or e7e8q. The null move 0000 is the zero move
+func (b *Board) resolveUCI(uci string) (move, error) {
+	if uci == "0000" {
+		return move{}, nil
+	}
+	if len(uci) != 4 && len(uci) != 5 {
+		return move{}, fmt.Errorf("uci %q is not a valid move", uci)
+	}
+	from, err := parseSquare(uci[0:2])
+	if err != nil {
+		return move{}, err
+	}
+	to, err := parseSquare(uci[2:4])
+	if err != nil {
+		return move{}, err
+	}
+	var promotes uint8
+	if len(uci) == 5 {
+		promotes = uint8(strings.IndexByte("pnbrqk", uci[4]) + 1)
+		if promotes < pKNIGHT || promotes > pQUEEN {
+			return move{}, fmt.Errorf("uci %q is not a valid move", uci)
+		}
+	}
+	for _, m := range b.legalMoves() {
+		if m.from == from && m.to == to && m.promotes == promotes {
+			return m, nil
+		}
+	}
+	return move{}, fmt.Errorf("uci %q is not a legal move", uci)
+}
+
+// SANToUCI returns the legal move san of the side to move in UCI
+// notation, like g1f3 for Nf3. Castling is the king move, e1g1 for O-O
+func (b *Board) SANToUCI(san string) (string, error) {
+	m, err := b.resolveSAN(san, b.activeMove)
+	if err != nil {
+		return "", err
+	}
+	return uci(m), nil
+}
+
+// UCIToSAN returns the legal move in UCI notation of the side to move
+// in SAN, with the check and checkmate suffixes
+func (b *Board) UCIToSAN(uci string) (string, error) {
+	m, err := b.resolveUCI(uci)
+	if err != nil {
+		return "", err
+	}
+	return b.san(m), nil
+}
+
+// MakeUCIMove is like MakeMove but for moves in the UCI coordinate
+// notation of chess engines, like e2e4, e1g1 for castling or e7e8q
+func (b *Board) MakeUCIMove(uci string) error {
+	m, err := b.resolveUCI(uci)
+	if err == nil {
+		b.makeMove(m, b.san(m))
+	}
+	return err
+}
+
+// PlayUCILine makes the UCI moves in turn, like the principal variation
+// an engine reports. It stops at the first illegal move with an error
+// that has its index, leaving the moves before it made
+func (b *Board) PlayUCILine(moves []string) error {
+	for i, uci := range moves {
+		if err := b.MakeUCIMove(uci); err != nil {
+			return fmt.Errorf("move %d: %s", i+1, err)
+		}
+	}
+	return nil
+}
+
+// destinations returns the squares the piece of type typ on square can
+// legally move to. It is empty if square does not hold such a piece of
+// the side to move
+func (b *Board) destinations(square string, typ uint8) []string {
+	s := make([]string, 0)
+	from, err := parseSquare(square)
+	if err != nil || b.sq[from] == 0 {
+		return s
+	}
+	if c, t := b.sq[from].identify(); c != b.activeMove || t != typ {
+		return s
+	}
+	for _, m := range b.legalMoves() {
+		if m.from == from && (len(s) == 0 || s[len(s)-1] != sq2string(m.to)) {
+			s = append(s, sq2string(m.to))
+		}
+	}
+	return s
+}
+
+// LegalMovesFrom returns the legal moves in SAN of the piece on square.
+// It is empty if square is empty or holds a piece of the opponent
+func (b *Board) LegalMovesFrom(square string) []string {
+	s := make([]string, 0)
+	from, err := parseSquare(square)
+	if err != nil || b.sq[from] == 0 {
+		return s
+	}
+	if c, _ := b.sq[from].identify(); c != b.activeMove {
+		return s
+	}
+	for _, m := range b.legalMoves() {
+		if m.from == from {
+			s = append(s, b.san(m))
+		}
+	}
+	return s
+}
+
+// MoveFromTo returns the SAN of the move of the piece on from to to
+// if it is a legal move of the side to move, as a GUI needs when a piece
+// is dropped on a square. A pawn reaching the last rank promotes to a
+// queen, other promotions must be made with MakeMove. Castling is the
+// king moving two squares
+func (b *Board) MoveFromTo(from, to string) (san string, legal bool) {
+	f, err := parseSquare(from)
+	if err != nil {
+		return "", false
+	}
+	t, err := parseSquare(to)
+	if err != nil {
+		return "", false
+	}
+	for _, m := range b.legalMoves() {
+		if m.from == f && m.to == t && (m.promotes == 0 || m.promotes == pQUEEN) {
+			return b.san(m), true
+		}
+	}
+	return "", false
+}
+
+// KnightMovesFrom returns the squares the knight on square can move to.
+// Like the rest of the XxxMovesFrom methods it returns only legal moves
+// and it is empty if square does not hold such a piece of the side to move
+func (b *Board) KnightMovesFrom(square string) []string {
+	return b.destinations(square, pKNIGHT)
+}
+
+// BishopMovesFrom returns the squares the bishop on square can move to
+func (b *Board) BishopMovesFrom(square string) []string {
+	return b.destinations(square, pBISHOP)
+}
+
+// RookMovesFrom returns the squares the rook on square can move to
+func (b *Board) RookMovesFrom(square string) []string {
+	return b.destinations(square, pROOK)
+}
+
+// QueenMovesFrom returns the squares the queen on square can move to
+func (b *Board) QueenMovesFrom(square string) []string {
+	return b.destinations(square, pQUEEN)
+}
+
+// KingMovesFrom returns the squares the king on square can move to,
+// including the castling destinations
+func (b *Board) KingMovesFrom(square string) []string {
+	return b.destinations(square, pKING)
+}
+
+// PawnMovesFrom returns the squares the pawn on square can move to
+func (b *Board) PawnMovesFrom(square string) []string {
+	return b.destinations(square, pPAWN)
+}
+
+// Status returns the state of the game for the side to move: one of
+// "ongoing", "check", "checkmate" or "stalemate". It is computed once
+// after each move and kept until the board changes again
+func (b *Board) Status() string {
+	if b.status == "" {
+		hasMoves := len(b.legalMoves()) != 0
+		switch {
+		case b.inCheck() && hasMoves:
+			b.status = "check"
+		case b.inCheck():
+			b.status = "checkmate"
+		case hasMoves:
+			b.status = "ongoing"
+		default:
+			b.status = "stalemate"
+		}
+	}
+	return b.status
+}
+
+// PlayRandomGame plays uniformly random legal moves on the board until
+// checkmate, stalemate, a draw by the fifty-move rule, threefold repetition
+// or bare kings, or until maxMoves plies are played. It returns the moves
+// played in SAN. It is meant for fuzzing and demos
+func (b *Board) PlayRandomGame(rng *rand.Rand, maxMoves int) []string {
+	sans := make([]string, 0, max(maxMoves, 0))
+	seen := map[uint64]int{b.RepetitionKey(): 1}
+	for len(sans) < maxMoves && b.halfmoves < 100 && b.PieceCount() > 2 {
+		moves := b.legalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		m := moves[rng.Intn(len(moves))]
+		san := b.san(m)
+		b.makeMove(m, san)
+		sans = append(sans, san)
+		key := b.RepetitionKey()
+		if seen[key]++; seen[key] == 3 {
+			break
+		}
+	}
+	return sans
+}
+
+// MatedColor returns the side that is checkmated, "white" or "black",
+// and true if the position is a checkmate. Otherwise it returns "", false
+func (b *Board) MatedColor() (color string, mated bool) {
+	if b.Status() != "checkmate" {
+		return "", false
+	}
+	return boolAsColor(b.activeMove == cWHITE), true
+}
@@ -0,0 +1,613 @@
+package gochess
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	kiwipeteFEN  = "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"
+	position3FEN = "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1"
+	position4FEN = "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"
+)
+
+func mustFEN(t testing.TB, fen string) *Board {
+	t.Helper()
+	b, err := NewBoardFromFen(fen)
+	if err != nil {
+		t.Fatalf("NewBoardFromFen(%q): %v", fen, err)
+	}
+	return b
+}
+
+var perftTests = []struct {
+	fen   string
+	depth int
+	nodes uint64
+}{
+	{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 4, 197281},
+	{kiwipeteFEN, 1, 48},
+	{kiwipeteFEN, 2, 2039},
+	{kiwipeteFEN, 3, 97862},
+	{position3FEN, 4, 43238},
+	{position4FEN, 3, 9467},
+}
+
+func TestPerft(t *testing.T) {
+	for _, tt := range perftTests {
+		if n := mustFEN(t, tt.fen).Perft(tt.depth); n != tt.nodes {
+			t.Errorf("Perft(%d) of %s = %d, want %d", tt.depth, tt.fen, n, tt.nodes)
+		}
+	}
+}
+
+func TestPerftParallel(t *testing.T) {
+	b := NewBoard()
+	if n, want := b.PerftParallel(4, 4), b.Perft(4); n != want {
+		t.Errorf("PerftParallel(4, 4) = %d, want %d", n, want)
+	}
+	b = mustFEN(t, kiwipeteFEN)
+	if n := b.PerftParallel(2, 8); n != 2039 {
+		t.Errorf("PerftParallel(2, 8) of kiwipete = %d, want 2039", n)
+	}
+}
+
+func TestSANToUCI(t *testing.T) {
+	tests := []struct {
+		fen, san, uci string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "Nf3", "g1f3"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4", "e2e4"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=Q", "e7e8q"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8=N", "e7e8n"},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		uci, err := b.SANToUCI(tt.san)
+		if err != nil || uci != tt.uci {
+			t.Errorf("SANToUCI(%q) in %s = %q, %v, want %q", tt.san, tt.fen, uci, err, tt.uci)
+		}
+		san, err := b.UCIToSAN(tt.uci)
+		if err != nil || san != tt.san {
+			t.Errorf("UCIToSAN(%q) in %s = %q, %v, want %q", tt.uci, tt.fen, san, err, tt.san)
+		}
+	}
+}
+
+func TestMissingPromotion(t *testing.T) {
+	b := mustFEN(t, "8/4P3/8/8/8/8/k7/4K3 w - - 0 1")
+	fen := b.Fen()
+	if uci, err := b.SANToUCI("e8"); err == nil {
+		t.Errorf("SANToUCI(e8) = %q, want an error", uci)
+	}
+	if err := b.MakeMove("e8"); err == nil {
+		t.Errorf("MakeMove(e8) succeeded: %s", b.Fen())
+	}
+	if _, err := b.UCIToSAN("e7e8"); err == nil {
+		t.Errorf("UCIToSAN(e7e8) succeeded")
+	}
+	if b.Fen() != fen {
+		t.Errorf("board changed: %s, want %s", b.Fen(), fen)
+	}
+	if err := NewBoard().MakeMove("e4=Q"); err == nil {
+		t.Errorf("MakeMove(e4=Q) succeeded")
+	}
+}
+
+func TestImpossiblePawnMoves(t *testing.T) {
+	tests := []struct {
+		fen, san, uci string
+	}{
+		{"rnbqkbnr/1pp1pppp/p7/3p4/4P3/P7/1PPP1PPP/RNBQKBNR w KQkq - 0 3", "exf5", "e4f5"},
+		{"4k3/8/8/8/8/4P3/8/4K3 w - - 0 1", "e5", "e3e5"},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		if uci, err := b.SANToUCI(tt.san); err == nil {
+			t.Errorf("SANToUCI(%s) in %s = %q, want an error", tt.san, tt.fen, uci)
+		}
+		if san, err := b.UCIToSAN(tt.uci); err == nil {
+			t.Errorf("UCIToSAN(%s) in %s = %q, want an error", tt.uci, tt.fen, san)
+		}
+		if err := b.MakeMove(tt.san); err == nil {
+			t.Errorf("MakeMove(%s) in %s succeeded: %s", tt.san, tt.fen, b.Fen())
+		}
+		if b.Fen() != tt.fen {
+			t.Errorf("board changed: %s, want %s", b.Fen(), tt.fen)
+		}
+	}
+}
+
+var countTestFENs = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	kiwipeteFEN,
+	position3FEN,
+	position4FEN,
+	"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+	"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	"8/8/8/KPp4r/8/8/8/7k w - c6 0 1",
+}
+
+func TestCountLegalMoves(t *testing.T) {
+	var walk func(b *Board, depth int)
+	walk = func(b *Board, depth int) {
+		if n, want := b.CountLegalMoves(), len(b.legalMoves()); n != want {
+			t.Fatalf("CountLegalMoves() of %s = %d, want %d", b.Fen(), n, want)
+		}
+		if depth == 0 {
+			return
+		}
+		for _, m := range b.legalMoves() {
+			c := b.clone()
+			c.makeMove(m, "")
+			walk(c, depth-1)
+		}
+	}
+	for _, fen := range countTestFENs {
+		walk(mustFEN(t, fen), 2)
+	}
+	b := mustFEN(t, kiwipeteFEN)
+	if allocs := testing.AllocsPerRun(100, func() { b.CountLegalMoves() }); allocs != 0 {
+		t.Errorf("CountLegalMoves() allocates %v times", allocs)
+	}
+}
+
+func BenchmarkCountLegalMoves(b *testing.B) {
+	board := mustFEN(b, kiwipeteFEN)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		board.CountLegalMoves()
+	}
+}
+
+func BenchmarkCountLegalMovesSAN(b *testing.B) {
+	board := mustFEN(b, kiwipeteFEN)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		n := 0
+		for _, m := range board.legalMoves() {
+			if board.san(m) != "" {
+				n++
+			}
+		}
+	}
+}
+
+func TestLANMoves(t *testing.T) {
+	moves := NewBoard().LANMoves()
+	if len(moves) != 20 {
+		t.Errorf("LANMoves() of the initial position returned %d moves, want 20", len(moves))
+	}
+	for _, want := range []string{"e2-e4", "Ng1-f3", "a2-a3"} {
+		if !contains(moves, want) {
+			t.Errorf("LANMoves() = %v, want %s in it", moves, want)
+		}
+	}
+	moves = mustFEN(t, "1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1").LANMoves()
+	for _, want := range []string{"a7-a8=Q", "a7-a8=N", "a7xb8=R", "Ke1-d2"} {
+		if !contains(moves, want) {
+			t.Errorf("LANMoves() = %v, want %s in it", moves, want)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGenerateMoves(t *testing.T) {
+	moves := NewBoard().GenerateMoves()
+	if len(moves) != 20 {
+		t.Errorf("GenerateMoves() of the initial position returned %d moves, want 20", len(moves))
+	}
+	found := false
+	for _, m := range moves {
+		if m.From == "e2" && m.To == "e4" {
+			found = m.Piece == "P" && m.Captured == "" && m.Flags == 0
+		}
+	}
+	if !found {
+		t.Errorf("GenerateMoves() = %+v, want a pawn move e2e4", moves)
+	}
+
+	b := mustFEN(t, "r3k3/1P6/8/3pP3/8/8/8/R3K2R w KQq d6 0 1")
+	want := map[string]Move{
+		"e5d6": {From: "e5", To: "d6", Piece: "P", Captured: "P", Flags: FlagCapture | FlagEnPassant},
+		"e1g1": {From: "e1", To: "g1", Piece: "K", Flags: FlagCastling},
+		"b7a8": {From: "b7", To: "a8", Piece: "P", Captured: "R", Promotion: "Q", Flags: FlagCapture | FlagPromotion},
+	}
+	for _, m := range b.GenerateMoves() {
+		if w, ok := want[m.From+m.To]; ok && (m.Promotion == "Q" || m.Promotion == "") {
+			if m != w {
+				t.Errorf("move %s%s = %+v, want %+v", m.From, m.To, m, w)
+			}
+			delete(want, m.From+m.To)
+		}
+	}
+	if len(want) != 0 {
+		t.Errorf("moves not generated: %v", want)
+	}
+}
+
+func TestExplainIllegal(t *testing.T) {
+	tests := []struct {
+		fen, san, want string
+	}{
+		{"4k3/8/8/8/8/8/4r3/4K3 w - - 0 1", "Kxe2", ""},
+		{"4k3/4r3/8/8/8/8/4B3/4K3 w - - 0 1", "Bd3", "leaves the king in check"},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rd1", "ambiguous"},
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rad1", ""},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Nf3", "no such piece can reach the square"},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Rf1", ""},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Rg2", "no such piece can reach the square"},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Ke1", "the square is occupied by a piece of the same color"},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "O-O", "the king or the rook has moved"},
+		{"4k3/8/8/8/8/8/5r2/R3K2R w KQ - 0 1", "O-O", "the king passes through or lands on an attacked square"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8", "the pawn must promote"},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "e4=Q", "only pawns on the last rank promote"},
+		{"4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "xx", "not a valid SAN move"},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "exd5", ""},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "d5", "no such piece can reach the square"},
+		{"rnbqkbnr/1pp1pppp/p7/3p4/4P3/P7/1PPP1PPP/RNBQKBNR w KQkq - 0 3", "exf5", "no such piece can reach the square"},
+	}
+	for _, tt := range tests {
+		b := mustFEN(t, tt.fen)
+		if s := b.ExplainIllegal(tt.san); s != tt.want {
+			t.Errorf("ExplainIllegal(%s) in %s = %q, want %q", tt.san, tt.fen, s, tt.want)
+		}
+		if err := b.MakeMove(tt.san); (err == nil) != (tt.want == "") {
+			t.Errorf("MakeMove(%s) in %s = %v, disagrees with ExplainIllegal", tt.san, tt.fen, err)
+		}
+	}
+}
+
+func TestPlayUCILine(t *testing.T) {
+	b := NewBoard()
+	if err := b.PlayUCILine([]string{"e2e4", "c7c5", "g1f3"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2"; b.Fen() != want {
+		t.Errorf("Fen() = %s, want %s", b.Fen(), want)
+	}
+	if san, _, _ := b.LastMove(); san != "Nf3" {
+		t.Errorf("LastMove() = %s, want Nf3", san)
+	}
+	err := b.PlayUCILine([]string{"d7d6", "d2d5", "d2d4"})
+	if err == nil || !strings.HasPrefix(err.Error(), "move 2:") {
+		t.Errorf("PlayUCILine with an illegal second move = %v", err)
+	}
+	if want := "rnbqkbnr/pp2pppp/3p4/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 0 3"; b.Fen() != want {
+		t.Errorf("Fen() after the error = %s, want %s", b.Fen(), want)
+	}
+	b = mustFEN(t, "r3k3/1P6/8/8/8/8/8/4K2R w K - 0 1")
+	if err := b.PlayUCILine([]string{"e1g1", "e8d7", "b7a8n"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "N7/3k4/8/8/8/8/8/5RK1 b - - 0 2"; b.Fen() != want {
+		t.Errorf("Fen() = %s, want %s", b.Fen(), want)
+	}
+}
+
+func TestPieceMovesFrom(t *testing.T) {
+	b := NewBoard()
+	if s := b.KnightMovesFrom("b1"); !sameSquares(s, []string{"a3", "c3"}) {
+		t.Errorf("KnightMovesFrom(b1) = %v, want a3 c3", s)
+	}
+	if s := b.BishopMovesFrom("c1"); len(s) != 0 {
+		t.Errorf("BishopMovesFrom(c1) of the blocked bishop = %v, want none", s)
+	}
+	if s := b.PawnMovesFrom("e2"); !sameSquares(s, []string{"e3", "e4"}) {
+		t.Errorf("PawnMovesFrom(e2) = %v, want e3 e4", s)
+	}
+	if s := b.KnightMovesFrom("c1"); len(s) != 0 {
+		t.Errorf("KnightMovesFrom(c1) with a bishop on c1 = %v, want none", s)
+	}
+	if s := b.KnightMovesFrom("g8"); len(s) != 0 {
+		t.Errorf("KnightMovesFrom(g8) of the side not to move = %v, want none", s)
+	}
+	b = mustFEN(t, "4k3/8/8/8/8/8/3P4/R3K2R w KQ - 0 1")
+	if s := b.KingMovesFrom("e1"); !sameSquares(s, []string{"d1", "e2", "f2", "f1", "g1", "c1"}) {
+		t.Errorf("KingMovesFrom(e1) = %v, want d1 e2 f2 f1 g1 c1", s)
+	}
+	if s := b.RookMovesFrom("a1"); !sameSquares(s, []string{"a2", "a3", "a4", "a5", "a6", "a7", "a8", "b1", "c1", "d1"}) {
+		t.Errorf("RookMovesFrom(a1) = %v", s)
+	}
+	b = mustFEN(t, "4k3/8/8/8/8/8/4r3/3QK3 w - - 0 1")
+	if s := b.QueenMovesFrom("d1"); !sameSquares(s, []string{"e2"}) {
+		t.Errorf("QueenMovesFrom(d1) in check = %v, want e2", s)
+	}
+	if s := b.LegalMovesFrom("d1"); !reflect.DeepEqual(s, []string{"Qxe2+"}) {
+		t.Errorf("LegalMovesFrom(d1) = %v, want [Qxe2+]", s)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	b := NewBoard()
+	if s := b.Status(); s != "ongoing" {
+		t.Errorf("Status() of the initial position = %q, want ongoing", s)
+	}
+	for _, san := range []string{"e4", "e5", "Qh5", "Nc6", "Bc4", "Nf6"} {
+		if err := b.MakeMove(san); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := b.Status(); s != "ongoing" {
+		t.Errorf("Status() = %q, want ongoing", s)
+	}
+	if err := b.MakeMove("Qxf7#"); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Status(); s != "checkmate" {
+		t.Errorf("Status() after Qxf7# = %q, want checkmate", s)
+	}
+	if err := b.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if s := b.Status(); s != "ongoing" {
+		t.Errorf("Status() after Undo = %q, want ongoing", s)
+	}
+	if s := mustFEN(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1").Status(); s != "stalemate" {
+		t.Errorf("Status() = %q, want stalemate", s)
+	}
+	if s := mustFEN(t, "4k3/8/8/8/8/8/8/R3K3 b - - 0 1").Status(); s != "ongoing" {
+		t.Errorf("Status() = %q, want ongoing", s)
+	}
+	if s := mustFEN(t, "R3k3/8/8/8/8/8/8/4K3 b - - 0 1").Status(); s != "check" {
+		t.Errorf("Status() = %q, want check", s)
+	}
+}
+
+func TestQuietMoves(t *testing.T) {
+	if moves := NewBoard().QuietMoves(); len(moves) != 20 {
+		t.Errorf("QuietMoves() of the initial position returned %d moves, want 20", len(moves))
+	}
+	b := mustFEN(t, "r3k3/1P6/8/3p4/4P3/8/8/4K2R w K - 0 1")
+	moves := b.QuietMoves()
+	for _, san := range []string{"exd5", "bxa8=Q", "b8=Q", "b8=N+"} {
+		if contains(moves, san) {
+			t.Errorf("QuietMoves() = %v has %s", moves, san)
+		}
+	}
+	for _, san := range []string{"O-O", "e5", "Rh8+", "Kd2"} {
+		if !contains(moves, san) {
+			t.Errorf("QuietMoves() = %v lacks %s", moves, san)
+		}
+	}
+}
+
+func TestLegalMovesFrom(t *testing.T) {
+	b := NewBoard()
+	if s := b.LegalMovesFrom("g1"); !sameSquares(s, []string{"Nf3", "Nh3"}) {
+		t.Errorf("LegalMovesFrom(g1) = %v, want Nf3 Nh3", s)
+	}
+	for _, square := range []string{"e4", "g8", "z1"} {
+		if s := b.LegalMovesFrom(square); len(s) != 0 {
+			t.Errorf("LegalMovesFrom(%s) = %v, want none", square, s)
+		}
+	}
+	b = mustFEN(t, "4k3/1P6/8/8/8/8/8/4K3 w - - 0 1")
+	if s := b.LegalMovesFrom("b7"); !sameSquares(s, []string{"b8=Q+", "b8=R+", "b8=B", "b8=N"}) {
+		t.Errorf("LegalMovesFrom(b7) = %v", s)
+	}
+}
+
+func TestPlayRandomGame(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		b := NewBoard()
+		sans := b.PlayRandomGame(rng, 300)
+		if err := b.Verify(); err != nil {
+			t.Fatalf("game %d: Verify(): %v", i, err)
+		}
+		replayed, repeated := NewBoard(), 0
+		if replayed.RepetitionKey() == b.RepetitionKey() {
+			repeated++
+		}
+		for _, san := range sans {
+			if err := replayed.MakeMove(san); err != nil {
+				t.Fatalf("game %d: replaying %v: %v", i, sans, err)
+			}
+			if replayed.RepetitionKey() == b.RepetitionKey() {
+				repeated++
+			}
+		}
+		if replayed.Fen() != b.Fen() {
+			t.Errorf("game %d: replayed %s, played %s", i, replayed.Fen(), b.Fen())
+		}
+		if len(sans) < 300 {
+			status := b.Status()
+			if status != "checkmate" && status != "stalemate" && b.halfmoves < 100 && b.PieceCount() > 2 && repeated < 3 {
+				t.Errorf("game %d stopped after %d plies in %s", i, len(sans), b.Fen())
+			}
+		}
+	}
+	if sans := mustFEN(t, "7k/5Q2/6K1/8/8/8/8/8 b - - 0 1").PlayRandomGame(rng, 10); len(sans) != 0 {
+		t.Errorf("PlayRandomGame() in stalemate played %v", sans)
+	}
+	if sans := NewBoard().PlayRandomGame(rng, -1); len(sans) != 0 {
+		t.Errorf("PlayRandomGame() with a negative limit played %v", sans)
+	}
+}
+
+func TestCastlingTargets(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "e5", "Nf3", "Nc6", "Bc4", "Bc5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := b.CastlingTargets(); !reflect.DeepEqual(s, []string{"g1"}) {
+		t.Errorf("CastlingTargets() = %v, want [g1]", s)
+	}
+	if s := NewBoard().CastlingTargets(); len(s) != 0 {
+		t.Errorf("CastlingTargets() of the initial position = %v, want none", s)
+	}
+	if s := mustFEN(t, "r3k2r/8/8/8/8/8/8/4K3 b kq - 0 1").CastlingTargets(); !sameSquares(s, []string{"g8", "c8"}) {
+		t.Errorf("CastlingTargets() = %v, want g8 c8", s)
+	}
+	if s := mustFEN(t, "r3k2r/8/8/8/8/8/8/4KR2 b kq - 0 1").CastlingTargets(); !reflect.DeepEqual(s, []string{"c8"}) {
+		t.Errorf("CastlingTargets() with f8 attacked = %v, want [c8]", s)
+	}
+}
+
+func TestPromotionMoves(t *testing.T) {
+	b := mustFEN(t, "1n2k3/P7/8/8/8/8/8/4K3 w - - 0 1")
+	want := []string{"a8=Q", "a8=R", "a8=B", "a8=N", "axb8=Q+", "axb8=R+", "axb8=B", "axb8=N"}
+	if s := b.PromotionMoves(); !sameSquares(s, want) {
+		t.Errorf("PromotionMoves() = %v, want %v", s, want)
+	}
+	if s := NewBoard().PromotionMoves(); len(s) != 0 {
+		t.Errorf("PromotionMoves() of the initial position = %v", s)
+	}
+}
+
+func TestCheckingPieces(t *testing.T) {
+	b := mustFEN(t, "4k3/8/3N4/8/8/8/8/4RK2 b - - 0 1")
+	if s := b.CheckingPieces(); !sameSquares(s, []string{"d6", "e1"}) {
+		t.Errorf("CheckingPieces() in double check = %v, want d6 e1", s)
+	}
+	if s := mustFEN(t, "4k3/8/8/8/8/8/8/4RK2 b - - 0 1").CheckingPieces(); !reflect.DeepEqual(s, []string{"e1"}) {
+		t.Errorf("CheckingPieces() = %v, want [e1]", s)
+	}
+	if s := NewBoard().CheckingPieces(); len(s) != 0 {
+		t.Errorf("CheckingPieces() of the initial position = %v", s)
+	}
+}
+
+func TestDescribeMove(t *testing.T) {
+	tests := []struct {
+		fen, san, want string
+	}{
+		{"8/8/8/6k1/8/5p2/8/4K1N1 w - - 0 1", "Nxf3+", "White knight from g1 captures the pawn on f3, giving check"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4", "White pawn from e2 moves to e4"},
+		{"r3k3/8/8/8/8/8/8/4K2R w K - 0 1", "O-O", "White castles kingside"},
+		{"r3k3/8/8/8/8/8/8/4K2R b q - 0 1", "O-O-O", "Black castles queenside"},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", "White pawn from e5 captures the pawn en passant on d6"},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a8=Q+", "White pawn from a7 moves to a8 and promotes to a queen, giving check"},
+		{"rnbqkbnr/pppp1ppp/8/4p3/6P1/5P2/PPPPP2P/RNBQKBNR b KQkq - 0 2", "Qh4#", "Black queen from d8 moves to h4, giving checkmate"},
+	}
+	for _, test := range tests {
+		b := mustFEN(t, test.fen)
+		fen := b.Fen()
+		got, err := b.DescribeMove(test.san)
+		if err != nil || got != test.want {
+			t.Errorf("DescribeMove(%s) = %q, %v, want %q", test.san, got, err, test.want)
+		}
+		if b.Fen() != fen {
+			t.Errorf("DescribeMove(%s) changed the board to %s", test.san, b.Fen())
+		}
+	}
+	if _, err := NewBoard().DescribeMove("Nf6"); err == nil {
+		t.Errorf("DescribeMove(Nf6) of white succeeded")
+	}
+}
+
+func TestMoveIsCaptureAndCastle(t *testing.T) {
+	tests := []struct {
+		fen, san        string
+		capture, castle bool
+	}{
+		{kiwipeteFEN, "Bxa6", true, false},
+		{kiwipeteFEN, "O-O", false, true},
+		{kiwipeteFEN, "O-O-O", false, true},
+		{kiwipeteFEN, "Kf1", false, false},
+		{"4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1", "exd6", true, false},
+	}
+	for _, test := range tests {
+		b := mustFEN(t, test.fen)
+		if capture, err := b.MoveIsCapture(test.san); err != nil || capture != test.capture {
+			t.Errorf("MoveIsCapture(%s) = %v, %v, want %v", test.san, capture, err, test.capture)
+		}
+		if castle, err := b.MoveIsCastle(test.san); err != nil || castle != test.castle {
+			t.Errorf("MoveIsCastle(%s) = %v, %v, want %v", test.san, castle, err, test.castle)
+		}
+	}
+	if _, err := NewBoard().MoveIsCapture("exd5"); err == nil {
+		t.Errorf("MoveIsCapture(exd5) of the initial position succeeded")
+	}
+	if _, err := NewBoard().MoveIsCastle("O-O"); err == nil {
+		t.Errorf("MoveIsCastle(O-O) of the initial position succeeded")
+	}
+	b := mustFEN(t, "rnbqkbnr/1pp1pppp/p7/3p4/4P3/P7/1PPP1PPP/RNBQKBNR w KQkq - 0 3")
+	if capture, err := b.MoveIsCapture("exf5"); err == nil {
+		t.Errorf("MoveIsCapture(exf5) onto an empty square = %v, want an error", capture)
+	}
+}
+
+const initialFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+func TestMoveFromTo(t *testing.T) {
+	tests := []struct {
+		fen, from, to, san string
+		legal              bool
+	}{
+		{initialFEN, "e2", "e4", "e4", true},
+		{initialFEN, "g1", "f3", "Nf3", true},
+		{initialFEN, "e2", "e5", "", false},
+		{initialFEN, "e7", "e5", "", false},
+		{initialFEN, "z9", "e4", "", false},
+		{"4k3/P7/8/8/8/8/8/4K3 w - - 0 1", "a7", "a8", "a8=Q+", true},
+		{"r3k3/8/8/8/8/8/8/4K2R w K - 0 1", "e1", "g1", "O-O", true},
+	}
+	for _, test := range tests {
+		if san, legal := mustFEN(t, test.fen).MoveFromTo(test.from, test.to); san != test.san || legal != test.legal {
+			t.Errorf("MoveFromTo(%s, %s) = %q, %v, want %q, %v", test.from, test.to, san, legal, test.san, test.legal)
+		}
+	}
+}
+
+func TestAnnotatedLegalMoves(t *testing.T) {
+	b := NewBoard()
+	moves := b.AnnotatedLegalMoves()
+	if len(moves) != 20 {
+		t.Errorf("AnnotatedLegalMoves() has %d moves, want 20", len(moves))
+	}
+	for _, m := range moves {
+		after := NewBoard()
+		if err := after.MakeMove(m.SAN); err != nil {
+			t.Errorf("MakeMove(%s): %v", m.SAN, err)
+		} else if m.FEN != after.Fen() {
+			t.Errorf("FEN of %s = %s, want %s", m.SAN, m.FEN, after.Fen())
+		}
+		if uci, err := b.SANToUCI(m.SAN); err != nil || uci != m.UCI {
+			t.Errorf("UCI of %s = %s, want %s", m.SAN, m.UCI, uci)
+		}
+	}
+	if b.Fen() != initialFEN {
+		t.Errorf("AnnotatedLegalMoves() changed the board to %s", b.Fen())
+	}
+	promotions := mustFEN(t, "4k3/P7/8/8/8/8/8/4K3 w - - 0 1").AnnotatedLegalMoves()
+	want := AnnotatedMove{"a8=N", "a7a8n", "N3k3/8/8/8/8/8/8/4K3 b - - 0 1"}
+	found := false
+	for _, m := range promotions {
+		found = found || m == want
+	}
+	if !found {
+		t.Errorf("AnnotatedLegalMoves() = %v, want %v among them", promotions, want)
+	}
+}
+
+func TestMatedColor(t *testing.T) {
+	tests := []struct {
+		fen, color string
+		mated      bool
+	}{
+		{"rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", "white", true},
+		{"r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4", "black", true},
+		{initialFEN, "", false},
+		{"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", "", false},
+		{"4k3/8/8/8/8/8/8/r3K3 w - - 0 1", "", false},
+	}
+	for _, test := range tests {
+		if color, mated := mustFEN(t, test.fen).MatedColor(); color != test.color || mated != test.mated {
+			t.Errorf("MatedColor() of %s = %q, %v, want %q, %v", test.fen, color, mated, test.color, test.mated)
+		}
+	}
+}
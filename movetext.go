@@ -0,0 +1,126 @@
+package gochess
+
+import (
+	"fmt"
+)
+
+// MoveTextBuilder constructs a move tree programmatically, the same
+// tree that ParseMovesText builds from PGN. The zero value is ready to
+// use and starts from the first move of white. Calls that make no sense,
+// like a NAG before any move, are recorded and reported by Err
+type MoveTextBuilder struct {
+	stack []*builderFrame
+	err   error
+}
+
+// builderFrame is a variation under construction. ply is its last ply
+// and moveNumber, white are the move number and color of the next ply
+type builderFrame struct {
+	variation  *Variation
+	ply        *Ply
+	moveNumber uint8
+	white      bool
+}
+
+// NewMoveTextBuilder returns a builder whose first move is the move
+// moveNumber of white or black. It is useful for games from a position
+func NewMoveTextBuilder(moveNumber uint8, white bool) *MoveTextBuilder {
+	return &MoveTextBuilder{stack: []*builderFrame{{variation: &Variation{}, moveNumber: moveNumber, white: white}}}
+}
+
+func (mb *MoveTextBuilder) top() *builderFrame {
+	if len(mb.stack) == 0 {
+		mb.stack = append(mb.stack, &builderFrame{variation: &Variation{}, moveNumber: 1, white: true})
+	}
+	return mb.stack[len(mb.stack)-1]
+}
+
+func (mb *MoveTextBuilder) fail(format string, args ...interface{}) *MoveTextBuilder {
+	if mb.err == nil {
+		mb.err = fmt.Errorf(format, args...)
+	}
+	return mb
+}
+
+// AddMove adds a ply to the current variation
+func (mb *MoveTextBuilder) AddMove(san string) *MoveTextBuilder {
+	if san != "--" && !san_re.MatchString(san) {
+		return mb.fail("mismatched SAN '%s'", san)
+	}
+	f := mb.top()
+	if len(f.variation.Plies) == 0 {
+		f.variation.MoveNumber, f.variation.WhiteMove = f.moveNumber, f.white
+	}
+	f.ply = &Ply{SAN: san}
+	f.variation.Plies = append(f.variation.Plies, f.ply)
+	if f.white = !f.white; f.white {
+		f.moveNumber++
+	}
+	return mb
+}
+
+// AddComment adds a comment to the last ply of the current variation
+// or to the variation itself if it has no plies yet
+func (mb *MoveTextBuilder) AddComment(text string) *MoveTextBuilder {
+	if f := mb.top(); f.ply != nil {
+		f.ply.Comment += text
+	} else {
+		f.variation.Comment += text
+	}
+	return mb
+}
+
+// AddNAG adds a numeric annotation glyph to the last ply of the current variation
+func (mb *MoveTextBuilder) AddNAG(n uint8) *MoveTextBuilder {
+	f := mb.top()
+	if f.ply == nil {
+		return mb.fail("NAG $%d before any move", n)
+	}
+	f.ply.Nags = append(f.ply.Nags, n)
+	return mb
+}
+
+// BeginVariation starts a variation that is an alternative to the
+// last ply of the current variation. The moves added until the
+// matching EndVariation go to it
+func (mb *MoveTextBuilder) BeginVariation() *MoveTextBuilder {
+	f := mb.top()
+	if f.ply == nil {
+		return mb.fail("RAV before any move")
+	}
+	// the variation replaces the last ply so it starts where that ply did
+	number, white := f.moveNumber, !f.white
+	if !white {
+		number--
+	}
+	mb.stack = append(mb.stack, &builderFrame{variation: &Variation{}, moveNumber: number, white: white})
+	return mb
+}
+
+// EndVariation ends the current variation and continues with its parent
+func (mb *MoveTextBuilder) EndVariation() *MoveTextBuilder {
+	if len(mb.stack) < 2 {
+		return mb.fail("non matched EndVariation")
+	}
+	f := mb.stack[len(mb.stack)-1]
+	mb.stack = mb.stack[:len(mb.stack)-1]
+	f.variation.Result = "*"
+	parent := mb.top()
+	parent.ply.Variations = append(parent.ply.Variations, *f.variation)
+	return mb
+}
+
+// Build returns the main variation. Variations that are still open are
+// ended first. The Result of the returned variation is empty and should
+// be set by the caller
+func (mb *MoveTextBuilder) Build() Variation {
+	for len(mb.stack) > 1 {
+		mb.EndVariation()
+	}
+	return *mb.top().variation
+}
+
+// Err returns the first invalid call made to the builder, if any
+func (mb *MoveTextBuilder) Err() error {
+	return mb.err
+}
@@ -0,0 +1,45 @@
+package gochess
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMoveTextBuilder(t *testing.T) {
+	text := "{Start} 1. e4 e5 $1 {solid} (1... c5 2. Nf3 (2. Nc3 Nc6) d6) 2. Nf3 *"
+	want := mustParseMoves(t, text).Moves
+
+	var mb MoveTextBuilder
+	mb.AddComment("Start").AddMove("e4").AddMove("e5").AddNAG(1).AddComment("solid")
+	mb.BeginVariation().AddMove("c5").AddMove("Nf3")
+	mb.BeginVariation().AddMove("Nc3").AddMove("Nc6").EndVariation()
+	mb.AddMove("d6").EndVariation()
+	mb.AddMove("Nf3")
+	got := mb.Build()
+	got.Result = "*"
+	if err := mb.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("built tree %s differs from the parsed %s", got.MoveText(), want.MoveText())
+	}
+}
+
+func TestMoveTextBuilderErrors(t *testing.T) {
+	if err := new(MoveTextBuilder).AddNAG(1).Err(); err == nil {
+		t.Errorf("NAG before any move is not an error")
+	}
+	if err := new(MoveTextBuilder).BeginVariation().Err(); err == nil {
+		t.Errorf("variation before any move is not an error")
+	}
+	if err := new(MoveTextBuilder).AddMove("e4").EndVariation().Err(); err == nil {
+		t.Errorf("unmatched EndVariation is not an error")
+	}
+	if err := new(MoveTextBuilder).AddMove("e9").Err(); err == nil {
+		t.Errorf("invalid SAN is not an error")
+	}
+	v := NewMoveTextBuilder(20, false).AddMove("Nf6").AddMove("Nc3").Build()
+	if v.MoveNumber != 20 || v.WhiteMove || v.MoveText() != "20... Nf6 21. Nc3" {
+		t.Errorf("builder from move 20 of black built %q", v.MoveText())
+	}
+}
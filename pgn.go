@@ -7,6 +7,8 @@ import (
 	"io"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type pgnToken int
@@ -29,16 +31,19 @@ const (
 	pgnIDENTIFIER
 	pgnCOMMENT
 	pgnTOKEN
+	pgnERROR
 )
 
 const (
 	pgnSAN_REGEXP = "^(((O-O|O-O-O)|((P?|[RNBQK])[a-h]?[1-8]?x?[a-h][1-8](=[PRNBQK])?))(\\+|#)?)$"
 	pgnTAG_REGEXP = `^\[?\s*([A-Za-z0-9_]+)\s+"(.*)"\s*\]`
+	pgnCMD_REGEXP = `\[%([A-Za-z0-9_]+)\s+([^\]]*)\]`
 )
 
 var (
 	san_re    *regexp.Regexp = regexp.MustCompile(pgnSAN_REGEXP)
 	tag_re    *regexp.Regexp = regexp.MustCompile(pgnTAG_REGEXP)
+	cmd_re    *regexp.Regexp = regexp.MustCompile(pgnCMD_REGEXP)
 	whiteWins []byte         = []byte("1-0")
 	blackWins []byte         = []byte("0-1")
 	drawRes   []byte         = []byte("1/2-1/2")
@@ -95,11 +100,55 @@ type Ply struct {
 	Nags []uint8
 	// Comment is the comment for the move
 	Comment string
+	// Elapsed is the time spent on the move as given by a [%emt] command
+	// in the comment. It is zero if the comment has no such command
+	Elapsed time.Duration
+	// Clock is the remaining time on the clock after the move as given by
+	// a [%clk] command in the comment. It is zero if there is no such command
+	Clock time.Duration
+	// Eval is the engine evaluation of a [%eval] command in the comment.
+	// It is nil if the comment has no such command
+	Eval *Evaluation
+	// Highlights are the colored squares of a [%csl] command in the comment
+	Highlights []SquareHighlight
+	// Arrows are the colored arrows of a [%cal] command in the comment
+	Arrows []Arrow
+	// Commands has the other [%key value] commands of the comment, like
+	// [%depth 22], by key without the %. It is nil if there are none
+	Commands map[string]string
 	// Variations is a slice of alternative moves at this point.
 	// In PGN they are represented as RAVs parenthesized variations
 	Variations []Variation
 }
 
+// Evaluation is an engine evaluation as in [%eval 0.25], [%eval #-3]
+// or [%eval 0.25,18] from the point of view of white
+type Evaluation struct {
+	// Pawns is the score in pawns, positive if white is better
+	Pawns float64
+	// Mate is the number of moves to mate, negative if black mates,
+	// or 0 if there is no mate
+	Mate int
+	// Depth is the search depth if the command has one, otherwise 0
+	Depth int
+}
+
+// SquareHighlight is a square that a GUI should color, as in [%csl Gd4]
+type SquareHighlight struct {
+	// Color is the color letter, usually one of R, G, Y, B
+	Color string
+	// Square is the square like d4
+	Square string
+}
+
+// Arrow is an arrow that a GUI should draw, as in [%cal Ge2e4]
+type Arrow struct {
+	// Color is the color letter, usually one of R, G, Y, B
+	Color string
+	// From and To are the squares the arrow starts and ends
+	From, To string
+}
+
 type token struct {
 	typ pgnToken
 	val string
@@ -107,6 +156,13 @@ type token struct {
 
 type tokenizer struct {
 	text []byte
+	// lenient if true records move number mismatches in warnings
+	// instead of failing and trusts the order of the moves
+	lenient  bool
+	warnings []string
+	// nestedComments if true lets { comments contain balanced
+	// braces. PGN does not allow them but some sources use them
+	nestedComments bool
 }
 
 func (p *Parser) readline() ([]byte, error) {
@@ -115,7 +171,25 @@ func (p *Parser) readline() ([]byte, error) {
 		p.line = nil
 		return s, nil
 	}
-	return p.input.ReadSlice('\n')
+	line, err := p.input.ReadSlice('\n')
+	for (err == nil || err == io.EOF) && len(line) > 0 && line[0] == '%' {
+		// escaped lines are not PGN data
+		line, err = p.input.ReadSlice('\n')
+	}
+	if err == bufio.ErrBufferFull {
+		// the line is longer than the buffer
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull {
+			line, err = p.input.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if err == io.EOF && len(line) > 0 {
+		// the last line has no newline
+		return line, nil
+	}
+	return line, err
 }
 
 func (p *Parser) unreadline(line []byte) {
@@ -123,7 +197,8 @@ func (p *Parser) unreadline(line []byte) {
 }
 
 // NewParser returns a new Parser for the input ReadCloser
-// The input stream must contain PGN data.
+// The input stream must contain PGN data. Any text before the first
+// tag is skipped and so are the lines that start with % anywhere.
 // It is not safe(yet) for concurrent access by multiple goroutines
 func NewParser(input io.Reader) *Parser {
 	p := &Parser{
@@ -143,7 +218,30 @@ func NewParser(input io.Reader) *Parser {
 	return p
 }
 
+// ParseBareMoves reads a movetext without tags, as some tools export
+// just the moves, and returns it as a Game with no tags and the moves
+// already parsed
+func ParseBareMoves(r io.Reader) (*Game, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	game := &Game{
+		Tags:      make(map[string]string),
+		PGNText:   text,
+		MovesText: text,
+	}
+	if err := game.ParseMovesText(); err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// matchTagLine matches a tag pair line. These lines mark
+// the boundaries of the games, so there is no need for blank
+// lines between the tags, the moves and the next game
 func matchTagLine(line []byte) [][]byte {
+	line = bytes.TrimLeft(line, " \t")
 	if len(line) > 0 && line[0] == '[' {
 		return tag_re.FindSubmatch(line)
 	}
@@ -209,6 +307,36 @@ func (p *Parser) NextGame() (*Game, error) {
 	return game, nil
 }
 
+// atBoundary reports whether a token of length n at the start
+// of the text ends at a token boundary
+func (t *tokenizer) atBoundary(n int) bool {
+	if n >= len(t.text) {
+		return true
+	}
+	switch t.text[n] {
+	case ' ', '\t', '\n', '\r', '\v', '{', ';', '(', ')':
+		return true
+	}
+	return false
+}
+
+// closingBrace returns the index of the } that balances the { at the
+// start of the text or -1 if there is none
+func (t *tokenizer) closingBrace() int {
+	depth := 0
+	for i, c := range t.text {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 func (t *tokenizer) next() token {
 	var k int
 	for k = 0; k < len(t.text); k++ {
@@ -229,11 +357,19 @@ func (t *tokenizer) next() token {
 		if t.text[0] == '{' {
 			delim = byte('}')
 		}
-		i := bytes.IndexByte(t.text, delim)
+		var i int
+		if delim == '}' && t.nestedComments {
+			i = t.closingBrace()
+		} else {
+			i = bytes.IndexByte(t.text, delim)
+		}
 		var tok string
 		if i >= 0 {
 			tok = string(t.text[1:i])
 			t.text = t.text[i+1:]
+		} else if delim == '}' {
+			t.text = t.text[len(t.text):]
+			return token{pgnERROR, "unterminated comment. unexpected EOF"}
 		} else {
 			tok = string(t.text[1:])
 			t.text = t.text[len(t.text):]
@@ -269,15 +405,16 @@ func (t *tokenizer) next() token {
 		}
 	}
 
-	if bytes.HasPrefix(t.text, whiteWins) {
+	// results must end at a token boundary
+	if bytes.HasPrefix(t.text, whiteWins) && t.atBoundary(len(whiteWins)) {
 		t.text = t.text[len(whiteWins):]
 		return token{pgnRESULT, "1-0"}
 	}
-	if bytes.HasPrefix(t.text, blackWins) {
+	if bytes.HasPrefix(t.text, blackWins) && t.atBoundary(len(blackWins)) {
 		t.text = t.text[len(blackWins):]
 		return token{pgnRESULT, "0-1"}
 	}
-	if bytes.HasPrefix(t.text, drawRes) {
+	if bytes.HasPrefix(t.text, drawRes) && t.atBoundary(len(drawRes)) {
 		t.text = t.text[len(drawRes):]
 		return token{pgnRESULT, "1/2-1/2"}
 	}
@@ -287,8 +424,13 @@ func (t *tokenizer) next() token {
 		return token{pgnPERIOD, "."}
 	}
 	if t.text[0] == '*' {
+		typ := pgnASTERISK
+		if !t.atBoundary(1) {
+			// not a standalone result
+			typ = pgnTOKEN
+		}
 		t.text = t.text[1:]
-		return token{pgnASTERISK, "*"}
+		return token{typ, "*"}
 	}
 	if t.text[0] == '[' {
 		t.text = t.text[1:]
@@ -365,12 +507,184 @@ func (t *tokenizer) next() token {
 
 // ParseMovesText parses the moves text of a game
 // and converts it to a tree of plies. It must be called
-// explicitly for each game
+// explicitly for each game. If the moves text does not end
+// with a result, the result is taken from the Result tag
 func (game *Game) ParseMovesText() error {
-	t := &tokenizer{
+	return game.parseMovesText(&tokenizer{
 		text: game.MovesText,
+	})
+}
+
+// ParseMovesTextLenient is like ParseMovesText but tolerates move
+// numbers that do not match the order of the moves, as many real world
+// files repeat or misnumber moves. The numbers are ignored and the
+// mismatches are returned as warnings
+func (game *Game) ParseMovesTextLenient() ([]string, error) {
+	return game.ParseMovesTextWithOptions(ParseOptions{Lenient: true})
+}
+
+// ParseOptions controls how ParseMovesTextWithOptions reads the moves text
+type ParseOptions struct {
+	// Lenient if true tolerates misnumbered moves like ParseMovesTextLenient
+	Lenient bool
+	// NestedComments if true lets comments contain balanced braces like
+	// {see {this} line}. PGN does not allow them but some sources use them.
+	// A comment with a lone { in it is then unterminated
+	NestedComments bool
+}
+
+// ParseMovesTextWithOptions is like ParseMovesText with the deviations
+// from PGN that opts allows. It returns the warnings of lenient parsing
+func (game *Game) ParseMovesTextWithOptions(opts ParseOptions) ([]string, error) {
+	t := &tokenizer{
+		text:           game.MovesText,
+		lenient:        opts.Lenient,
+		nestedComments: opts.NestedComments,
+	}
+	err := game.parseMovesText(t)
+	return t.warnings, err
+}
+
+func (game *Game) parseMovesText(t *tokenizer) error {
+	if err := t.generatePlies(&game.Moves, false, 1, true); err != nil {
+		return err
+	}
+	if game.Moves.Result == "" {
+		game.Moves.Result = "*"
+		switch res := game.Tags["Result"]; res {
+		case "1-0", "0-1", "1/2-1/2":
+			game.Moves.Result = res
+		}
+	}
+	return nil
+}
+
+// parseCommands extracts the embedded [%cmd value] commands from
+// a comment and returns the remaining text. The commands the ply
+// understands fill their fields and the rest go to Commands.
+// Known commands with invalid values are left in the comment untouched
+func (ply *Ply) parseCommands(comment string) string {
+	rest := cmd_re.ReplaceAllStringFunc(comment, func(cmd string) string {
+		matches := cmd_re.FindStringSubmatch(cmd)
+		switch matches[1] {
+		case "emt":
+			if d, err := parseClockTime(matches[2]); err == nil {
+				ply.Elapsed = d
+				return ""
+			}
+		case "clk":
+			if d, err := parseClockTime(matches[2]); err == nil {
+				ply.Clock = d
+				return ""
+			}
+		case "eval":
+			if e, err := parseEval(matches[2]); err == nil {
+				ply.Eval = e
+				return ""
+			}
+		case "csl":
+			if hs, ok := parseDrawing(matches[2], 1); ok {
+				for _, h := range hs {
+					ply.Highlights = append(ply.Highlights, SquareHighlight{h[0], h[1]})
+				}
+				return ""
+			}
+		case "cal":
+			if as, ok := parseDrawing(matches[2], 2); ok {
+				for _, a := range as {
+					ply.Arrows = append(ply.Arrows, Arrow{a[0], a[1], a[2]})
+				}
+				return ""
+			}
+		default:
+			if ply.Commands == nil {
+				ply.Commands = make(map[string]string)
+			}
+			ply.Commands[matches[1]] = strings.TrimSpace(matches[2])
+			return ""
+		}
+		return cmd
+	})
+	if rest != comment && strings.TrimSpace(rest) == "" {
+		rest = ""
+	}
+	return rest
+}
+
+// parseClockTime parses times like 1:02:03 or 0:00:12.5
+func parseClockTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("time %q is not valid", s)
+	}
+	var d time.Duration
+	for i, part := range parts {
+		unit := time.Second
+		switch len(parts) - i {
+		case 3:
+			unit = time.Hour
+		case 2:
+			unit = time.Minute
+		}
+		if unit == time.Second {
+			f, err := strconv.ParseFloat(part, 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("time %q is not valid", s)
+			}
+			d += time.Duration(f * float64(time.Second))
+		} else {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("time %q is not valid", s)
+			}
+			d += time.Duration(n) * unit
+		}
+	}
+	return d, nil
+}
+
+// parseEval parses evaluations like 0.25, #-3 or 0.25,18
+func parseEval(s string) (*Evaluation, error) {
+	e := &Evaluation{}
+	score, depth, found := strings.Cut(strings.TrimSpace(s), ",")
+	if found {
+		n, err := strconv.Atoi(depth)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("evaluation %q is not valid", s)
+		}
+		e.Depth = n
+	}
+	var err error
+	if strings.HasPrefix(score, "#") {
+		e.Mate, err = strconv.Atoi(score[1:])
+	} else {
+		e.Pawns, err = strconv.ParseFloat(score, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("evaluation %q is not valid", s)
+	}
+	return e, nil
+}
+
+// parseDrawing parses the comma separated list of a %csl or %cal command
+// into the color letter followed by nsquares squares for each item
+func parseDrawing(s string, nsquares int) ([][]string, bool) {
+	var items [][]string
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) != 1+2*nsquares || item[0] < 'A' || item[0] > 'Z' {
+			return nil, false
+		}
+		fields := []string{item[0:1]}
+		for i := 1; i < len(item); i += 2 {
+			if _, err := parseSquare(item[i : i+2]); err != nil {
+				return nil, false
+			}
+			fields = append(fields, item[i:i+2])
+		}
+		items = append(items, fields)
 	}
-	return t.generatePlies(&game.Moves, false, 1, true)
+	return items, true
 }
 
 func boolAsColor(b bool) string {
@@ -382,6 +696,12 @@ func boolAsColor(b bool) string {
 
 func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumber uint8, thisPlyWhite bool) error {
 	var ply *Ply
+	// the move number and color of ply. RAVs are alternatives to it
+	var plyMoveNumber uint8
+	var plyWhite bool
+	// comments between a move number and its move, like 2. {idea} Nf3,
+	// belong to the move
+	var before string
 
 	for token := t.next(); ; token = t.next() {
 	loop:
@@ -397,6 +717,20 @@ func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumb
 		case pgnRESULT, pgnASTERISK:
 			variation.Result = token.val
 			if !inRav {
+				// comments after the result, like 1-0 {White won on time},
+				// apply to the game as a whole
+				for rest := t.text; ; rest = t.text {
+					if token = t.next(); token.typ == pgnERROR {
+						return fmt.Errorf("%s", token.val)
+					} else if token.typ != pgnCOMMENT {
+						t.text = rest
+						break
+					}
+					if variation.Comment != "" {
+						variation.Comment += " "
+					}
+					variation.Comment += token.val
+				}
 				return nil
 			}
 
@@ -404,7 +738,6 @@ func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumb
 			if inRav {
 				return fmt.Errorf("non closing RAV. unexpected EOF")
 			} else {
-				variation.Result = "*"
 				return nil
 			}
 
@@ -414,13 +747,32 @@ func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumb
 			for token = t.next(); token.typ == pgnPERIOD; token = t.next() {
 				i++
 			}
+			for ; token.typ == pgnCOMMENT; token = t.next() {
+				before += token.val
+			}
+			// without a move to attach to they stay where they would have gone
+			if before != "" && token.typ != pgnSYMBOL && token.typ != pgnIDENTIFIER {
+				if ply != nil {
+					ply.Comment += ply.parseCommands(before)
+				} else {
+					variation.Comment += before
+				}
+				before = ""
+			}
 			m, p := uint8(n), i == 1
 			if variation.MoveNumber != 0 {
+				var mismatch string
 				if m != thisMoveNumber {
-					return fmt.Errorf("move number mismatch. Expected %d got %d", thisMoveNumber, m)
+					mismatch = fmt.Sprintf("move number mismatch. Expected %d got %d", thisMoveNumber, m)
+				} else if p != thisPlyWhite {
+					mismatch = fmt.Sprintf("move order mismatch. Expected %s got %s", boolAsColor(thisPlyWhite), boolAsColor(p))
 				}
-				if p != thisPlyWhite {
-					return fmt.Errorf("move order mismatch. Expected %d got %d", boolAsColor(thisPlyWhite), boolAsColor(p))
+				if mismatch != "" {
+					if !t.lenient {
+						return fmt.Errorf("%s", mismatch)
+					}
+					t.warnings = append(t.warnings, mismatch)
+					goto loop
 				}
 			} else {
 				variation.MoveNumber = m
@@ -442,28 +794,42 @@ func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumb
 			}
 			ply = &Ply{SAN: SAN}
 			variation.Plies = append(variation.Plies, ply)
+			if before != "" {
+				ply.Comment = ply.parseCommands(before)
+				before = ""
+			}
 			if variation.MoveNumber == 0 {
 				variation.MoveNumber = thisMoveNumber
 				variation.WhiteMove = thisPlyWhite
 			}
+			plyMoveNumber, plyWhite = thisMoveNumber, thisPlyWhite
 			if thisPlyWhite = !thisPlyWhite; thisPlyWhite {
 				thisMoveNumber++
 			}
 
 		case pgnNAG:
+			if ply == nil {
+				return fmt.Errorf("NAG before any move")
+			}
 			nag, _ := strconv.Atoi(token.val)
 			ply.Nags = append(ply.Nags, uint8(nag))
 
 		case pgnCOMMENT:
 			if ply != nil {
-				ply.Comment += token.val
+				ply.Comment += ply.parseCommands(token.val)
 			} else {
 				variation.Comment += token.val
 			}
 
+		case pgnERROR:
+			return fmt.Errorf("%s", token.val)
+
 		case pgnLPAREN:
+			if ply == nil {
+				return fmt.Errorf("RAV before any move")
+			}
 			var v Variation
-			if err := t.generatePlies(&v, true, thisMoveNumber, thisPlyWhite); err == nil {
+			if err := t.generatePlies(&v, true, plyMoveNumber, plyWhite); err == nil {
 				ply.Variations = append(ply.Variations, v)
 			} else {
 				return fmt.Errorf("cannot parse RAV section: %s", err)
@@ -500,5 +866,4 @@ func (t *tokenizer) generatePlies(variation *Variation, inRav bool, thisMoveNumb
 			}
 		}
 	}
-	return nil
 }
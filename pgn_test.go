@@ -0,0 +1,321 @@
+package gochess
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseMoves(t testing.TB, movetext string) *Game {
+	t.Helper()
+	game, err := ParseBareMoves(strings.NewReader(movetext))
+	if err != nil {
+		t.Fatalf("ParseBareMoves(%q): %v", movetext, err)
+	}
+	return game
+}
+
+func TestClockCommands(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 {[%clk 1:30:00] [%emt 0:00:12.5] good} e5 {[%clk 0:59:58]} *")
+	e4, e5 := game.Moves.Plies[0], game.Moves.Plies[1]
+	if want := 90 * time.Minute; e4.Clock != want {
+		t.Errorf("e4 clock = %v, want %v", e4.Clock, want)
+	}
+	if want := 12500 * time.Millisecond; e4.Elapsed != want {
+		t.Errorf("e4 elapsed = %v, want %v", e4.Elapsed, want)
+	}
+	if e4.Comment != "  good" {
+		t.Errorf("e4 comment = %q, want %q", e4.Comment, "  good")
+	}
+	if want := 59*time.Minute + 58*time.Second; e5.Clock != want || e5.Elapsed != 0 {
+		t.Errorf("e5 clock, elapsed = %v, %v, want %v, 0", e5.Clock, e5.Elapsed, want)
+	}
+	if e5.Comment != "" {
+		t.Errorf("e5 comment = %q, want empty", e5.Comment)
+	}
+	want := "1. e4 {[%clk 1:30:00][%emt 0:00:12.5]  good} 1... e5 {[%clk 0:59:58]}"
+	if text := game.Moves.MoveText(); text != want {
+		t.Errorf("MoveText() = %q, want %q", text, want)
+	}
+}
+
+func TestEvalAndUnknownCommands(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 {[%eval 0.25] [%depth 22]} e5 {[%eval #-3,18]} 2. Nf3 {[%eval x]} *")
+	plies := game.Moves.Plies
+	if e := plies[0].Eval; e == nil || e.Pawns != 0.25 || e.Mate != 0 || e.Depth != 0 {
+		t.Errorf("e4 eval = %+v, want 0.25", e)
+	}
+	if d := plies[0].Commands["depth"]; d != "22" {
+		t.Errorf("e4 depth command = %q, want 22", d)
+	}
+	if e := plies[1].Eval; e == nil || e.Mate != -3 || e.Depth != 18 {
+		t.Errorf("e5 eval = %+v, want #-3 at depth 18", e)
+	}
+	if plies[2].Eval != nil || plies[2].Comment != "[%eval x]" {
+		t.Errorf("Nf3 eval, comment = %+v, %q, want nil and the invalid command kept", plies[2].Eval, plies[2].Comment)
+	}
+	want := "1. e4 {[%eval 0.25][%depth 22]} 1... e5 {[%eval #-3,18]} 2. Nf3 {[%eval x]}"
+	if text := game.Moves.MoveText(); text != want {
+		t.Errorf("MoveText() = %q, want %q", text, want)
+	}
+}
+
+func TestCommentsBeforeMoves(t *testing.T) {
+	game := mustParseMoves(t, "{Start} 1. e4 {good} e5 2. {idea} Nf3 *")
+	plies := game.Moves.Plies
+	if game.Moves.Comment != "Start" {
+		t.Errorf("variation comment = %q, want Start", game.Moves.Comment)
+	}
+	if plies[0].Comment != "good" || plies[1].Comment != "" || plies[2].Comment != "idea" {
+		t.Errorf("ply comments = %q, %q, %q, want good, empty, idea", plies[0].Comment, plies[1].Comment, plies[2].Comment)
+	}
+
+	game = mustParseMoves(t, "1. {c} e4 e5 *")
+	if game.Moves.Comment != "" || game.Moves.Plies[0].Comment != "c" {
+		t.Errorf("comments = %q, %q, want empty, c", game.Moves.Comment, game.Moves.Plies[0].Comment)
+	}
+	game = mustParseMoves(t, "1. {[%clk 0:05:00]} e4 *")
+	if e4 := game.Moves.Plies[0]; e4.Clock != 5*time.Minute || e4.Comment != "" {
+		t.Errorf("e4 clock, comment = %v, %q, want 5m0s, empty", e4.Clock, e4.Comment)
+	}
+}
+
+func TestCommentsAfterResult(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 1-0 {White won on time}")
+	if game.Moves.Result != "1-0" || game.Moves.Comment != "White won on time" {
+		t.Errorf("result, comment = %q, %q", game.Moves.Result, game.Moves.Comment)
+	}
+	game = mustParseMoves(t, "{Opening} 1. e4 e5 1-0 {White won} {on time}")
+	if want := "Opening White won on time"; game.Moves.Comment != want {
+		t.Errorf("comment = %q, want %q", game.Moves.Comment, want)
+	}
+	if _, err := ParseBareMoves(strings.NewReader("1. e4 e5 1-0 {White won")); err == nil {
+		t.Errorf("unterminated comment after the result is not an error")
+	}
+}
+
+func mustParseGame(t testing.TB, pgn string) *Game {
+	t.Helper()
+	game, err := NewParser(strings.NewReader(pgn)).NextGame()
+	if err != nil || game == nil {
+		t.Fatalf("NextGame(): %v, %v", game, err)
+	}
+	if err := game.ParseMovesText(); err != nil {
+		t.Fatalf("ParseMovesText(): %v", err)
+	}
+	return game
+}
+
+func TestResultFromTag(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[Result \"1-0\"]\n\n1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7#\n")
+	if game.Moves.Result != "1-0" {
+		t.Errorf("result = %q, want 1-0", game.Moves.Result)
+	}
+	game = mustParseGame(t, "[Event \"x\"]\n\n1. e4 e5\n")
+	if game.Moves.Result != "*" {
+		t.Errorf("result without a tag = %q, want *", game.Moves.Result)
+	}
+	game = mustParseGame(t, "[Event \"x\"]\n[Result \"1-0\"]\n\n1. e4 e5 0-1\n")
+	if game.Moves.Result != "0-1" {
+		t.Errorf("result of the movetext = %q, want 0-1", game.Moves.Result)
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	game := &Game{Tags: map[string]string{}, MovesText: []byte("1. e4 {a comment that never ends e5 2. Nf3")}
+	err := game.ParseMovesText()
+	if err == nil || !strings.Contains(err.Error(), "unterminated comment") {
+		t.Errorf("ParseMovesText() = %v, want an unterminated comment error", err)
+	}
+	game = &Game{Tags: map[string]string{}, MovesText: []byte("1. e4 ; a line comment to the end")}
+	if err := game.ParseMovesText(); err != nil {
+		t.Errorf("line comment at the end: %v", err)
+	}
+}
+
+func TestAsteriskInComment(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 {the * is not a result} e5 2. Nf3 *")
+	if n := len(game.Moves.Plies); n != 3 || game.Moves.Result != "*" {
+		t.Errorf("%d plies and result %q, want 3 and *", n, game.Moves.Result)
+	}
+	if c := game.Moves.Plies[0].Comment; c != "the * is not a result" {
+		t.Errorf("comment = %q", c)
+	}
+	if _, err := ParseBareMoves(strings.NewReader("1. e4 *e5 2. Nf3 *")); err == nil {
+		t.Errorf("asterisk glued to a move was read as a result")
+	}
+}
+
+func TestBlackContinuationRAV(t *testing.T) {
+	for _, text := range []string{
+		"12. e4 e5 (12... Nf6 13. Nc3) 13. Nf3 *",
+		"12. e4 e5 (Nf6 13. Nc3) 13. Nf3 *",
+	} {
+		game := mustParseMoves(t, text)
+		e5 := game.Moves.Plies[1]
+		if len(e5.Variations) != 1 {
+			t.Fatalf("%s: e5 has %d variations", text, len(e5.Variations))
+		}
+		v := e5.Variations[0]
+		if v.MoveNumber != 12 || v.WhiteMove || len(v.Plies) != 2 {
+			t.Errorf("%s: variation starts at %d, white %v, with %d plies, want 12, false, 2", text, v.MoveNumber, v.WhiteMove, len(v.Plies))
+		}
+	}
+	if _, err := ParseBareMoves(strings.NewReader("(1. d4) 1. e4 *")); err == nil {
+		t.Errorf("RAV before any move is not an error")
+	}
+}
+
+func TestGamesWithoutBlankLines(t *testing.T) {
+	pgn := "[Event \"one\"]\n[Result \"1-0\"]\n1. e4 e5 1-0\n[Event \"two\"]\n  [Result \"0-1\"]\n1. d4 d5 0-1"
+	p := NewParser(strings.NewReader(pgn))
+	var events []string
+	for {
+		game, err := p.NextGame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if game == nil {
+			break
+		}
+		if err := game.ParseMovesText(); err != nil {
+			t.Fatalf("game %s: %v", game.Tags["Event"], err)
+		}
+		if len(game.Moves.Plies) != 2 {
+			t.Errorf("game %s has %d plies, want 2", game.Tags["Event"], len(game.Moves.Plies))
+		}
+		events = append(events, game.Tags["Event"]+" "+game.Moves.Result)
+	}
+	if want := []string{"one 1-0", "two 0-1"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("games = %v, want %v", events, want)
+	}
+}
+
+func TestLongTagLine(t *testing.T) {
+	long := strings.Repeat("x", 3*4096)
+	game := mustParseGame(t, "[Event \"x\"]\n[Annotator \""+long+"\"]\n\n1. e4 e5 *\n")
+	if game.Tags["Annotator"] != long {
+		t.Errorf("long tag value has %d bytes, want %d", len(game.Tags["Annotator"]), len(long))
+	}
+	if len(game.Moves.Plies) != 2 {
+		t.Errorf("game after a long tag has %d plies, want 2", len(game.Moves.Plies))
+	}
+}
+
+func TestResultAtTokenBoundary(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 2. Nf3 1-0")
+	if game.Moves.Result != "1-0" || len(game.Moves.Plies) != 3 {
+		t.Errorf("result %q after %d plies, want 1-0 after 3", game.Moves.Result, len(game.Moves.Plies))
+	}
+	game = mustParseMoves(t, "10. e4 e5 11. Nf3 0-1")
+	if game.Moves.MoveNumber != 10 || game.Moves.Result != "0-1" || len(game.Moves.Plies) != 3 {
+		t.Errorf("move number %d, result %q, %d plies, want 10, 0-1, 3", game.Moves.MoveNumber, game.Moves.Result, len(game.Moves.Plies))
+	}
+	game = mustParseMoves(t, "1. e4 e5 1/2-1/2")
+	if game.Moves.Result != "1/2-1/2" {
+		t.Errorf("result = %q, want 1/2-1/2", game.Moves.Result)
+	}
+	if _, err := ParseBareMoves(strings.NewReader("1. e4 1-0x")); err == nil {
+		t.Errorf("1-0x was read as a result")
+	}
+}
+
+func TestParseMovesTextLenient(t *testing.T) {
+	text := "1. e4 e5 3. Nf3 Nc6 3... Bb5 *"
+	game := &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	if err := game.ParseMovesText(); err == nil {
+		t.Errorf("ParseMovesText() of a misnumbered game succeeded")
+	}
+	game = &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	warnings, err := game.ParseMovesTextLenient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q, want 2", warnings)
+	}
+	if want := "1. e4 e5 2. Nf3 Nc6 3. Bb5"; game.Moves.MoveText() != want {
+		t.Errorf("MoveText() = %q, want %q", game.Moves.MoveText(), want)
+	}
+}
+
+func TestNestedBracesInComments(t *testing.T) {
+	text := "1. e4 {a {nested} comment} e5 *"
+	game := &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	if _, err := game.ParseMovesTextWithOptions(ParseOptions{NestedComments: true}); err != nil {
+		t.Fatal(err)
+	}
+	if c := game.Moves.Plies[0].Comment; c != "a {nested} comment" || len(game.Moves.Plies) != 2 {
+		t.Errorf("comment = %q with %d plies, want the whole comment and 2 plies", c, len(game.Moves.Plies))
+	}
+	game = &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	if err := game.ParseMovesText(); err == nil {
+		t.Errorf("strict parsing accepted nested braces: %q", game.Moves.Plies[0].Comment)
+	}
+
+	text = "1. e4 {a { b} e5 *"
+	game = &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	if _, err := game.ParseMovesTextLenient(); err != nil {
+		t.Fatal(err)
+	}
+	if c := game.Moves.Plies[0].Comment; c != "a { b" || len(game.Moves.Plies) != 2 {
+		t.Errorf("lenient comment = %q with %d plies, want %q and 2 plies", c, len(game.Moves.Plies), "a { b")
+	}
+	game = &Game{Tags: map[string]string{}, MovesText: []byte(text)}
+	if _, err := game.ParseMovesTextWithOptions(ParseOptions{NestedComments: true}); err == nil {
+		t.Errorf("parsing with nested comments accepted a lone {")
+	}
+}
+
+func TestDrawingCommands(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 {[%csl Gd4,Rf6] center [%cal Ge2e4,Rg1f3]} e5 {[%csl Gz9]} *")
+	e4 := game.Moves.Plies[0]
+	if want := []SquareHighlight{{"G", "d4"}, {"R", "f6"}}; !reflect.DeepEqual(e4.Highlights, want) {
+		t.Errorf("highlights = %v, want %v", e4.Highlights, want)
+	}
+	if want := []Arrow{{"G", "e2", "e4"}, {"R", "g1", "f3"}}; !reflect.DeepEqual(e4.Arrows, want) {
+		t.Errorf("arrows = %v, want %v", e4.Arrows, want)
+	}
+	if e4.Comment != " center " {
+		t.Errorf("comment = %q, want %q", e4.Comment, " center ")
+	}
+	if e5 := game.Moves.Plies[1]; e5.Highlights != nil || e5.Comment != "[%csl Gz9]" {
+		t.Errorf("invalid command parsed to %v, comment %q", e5.Highlights, e5.Comment)
+	}
+	if want := "1. e4 {[%csl Gd4,Rf6][%cal Ge2e4,Rg1f3] center } 1... e5 {[%csl Gz9]}"; game.Moves.MoveText() != want {
+		t.Errorf("MoveText() = %q, want %q", game.Moves.MoveText(), want)
+	}
+}
+
+func TestPreambleAndEscapedLines(t *testing.T) {
+	pgn := "Games of the club\n% exported by a tool\n\n[Event \"a\"]\n% escaped\n[Site \"b\"]\n\n1. e4\n% escaped\ne5 *\n"
+	game := mustParseGame(t, pgn)
+	if want := map[string]string{"Event": "a", "Site": "b"}; !reflect.DeepEqual(game.Tags, want) {
+		t.Errorf("tags = %v, want %v", game.Tags, want)
+	}
+	if len(game.Moves.Plies) != 2 {
+		t.Errorf("moves = %q, want e4 e5", game.Moves.MoveText())
+	}
+}
+
+func TestParseBareMoves(t *testing.T) {
+	game, err := ParseBareMoves(strings.NewReader("1. e4 e5 2. Nf3 {the king's knight} 1-0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(game.Tags) != 0 {
+		t.Errorf("tags = %v, want none", game.Tags)
+	}
+	if game.Moves.Result != "1-0" || game.Moves.MoveText() != "1. e4 e5 2. Nf3 {the king's knight}" {
+		t.Errorf("moves = %q %s", game.Moves.MoveText(), game.Moves.Result)
+	}
+	if _, err := ParseBareMoves(strings.NewReader("1. e4 {unterminated")); err == nil {
+		t.Errorf("ParseBareMoves() of an unterminated comment succeeded")
+	}
+	for _, text := range []string{"$1 1. e4 *", "1. e4 ($2 1. d4) *"} {
+		if _, err := ParseBareMoves(strings.NewReader(text)); err == nil {
+			t.Errorf("ParseBareMoves(%q) of a NAG before any move succeeded", text)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package gochess
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pgnLINE_LENGTH = 79
+)
+
+var (
+	sevenTagRoster []string = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+	nagGlyphs [7]string = [7]string{"", "!", "?", "!!", "??", "!?", "?!"}
+)
+
+// WriteOptions controls how WritePGN formats a game
+type WriteOptions struct {
+	// GlyphAnnotations if true writes the move quality NAGs 1 to 6 as
+	// the suffixes !, ?, !!, ??, !?, ?! of the SAN instead of $1 to $6
+	GlyphAnnotations bool
+}
+
+// WritePGN writes the game to w in PGN format. The tags of the
+// seven tag roster come first in their standard order and the rest
+// follow sorted by name. The moves are written from Moves so
+// ParseMovesText must have been called before
+func (g *Game) WritePGN(w io.Writer, opts WriteOptions) error {
+	bw := bufio.NewWriter(w)
+	written := make(map[string]bool)
+	for _, name := range sevenTagRoster {
+		if value, ok := g.Tags[name]; ok {
+			writeTag(bw, name, value)
+			written[name] = true
+		}
+	}
+	names := make([]string, 0, len(g.Tags))
+	for name := range g.Tags {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeTag(bw, name, g.Tags[name])
+	}
+	bw.WriteString("\n")
+
+	result := g.Moves.Result
+	if result == "" {
+		result = "*"
+	}
+	toks := append(g.Moves.movetextTokens(opts), result)
+	n := 0
+	for _, tok := range toks {
+		if n > 0 && n+1+len(tok) > pgnLINE_LENGTH {
+			bw.WriteString("\n")
+			n = 0
+		}
+		if n > 0 {
+			bw.WriteString(" ")
+			n++
+		}
+		bw.WriteString(tok)
+		n += len(tok)
+	}
+	bw.WriteString("\n\n")
+	return bw.Flush()
+}
+
+// writeTag writes a tag pair. The value is written verbatim, as the
+// parser keeps it, so it must already have its quotes escaped
+func writeTag(w *bufio.Writer, name, value string) {
+	fmt.Fprintf(w, "[%s \"%s\"]\n", name, value)
+}
+
+// MoveText returns the movetext of the variation, its plies with their
+// NAGs, comments and variations, on a single line without the result.
+// The numbering starts from the MoveNumber and WhiteMove of the variation
+func (v *Variation) MoveText() string {
+	return strings.Join(v.movetextTokens(WriteOptions{}), " ")
+}
+
+// movetextTokens returns the movetext of the variation, without the result,
+// as a list of tokens to be separated by spaces
+func (v *Variation) movetextTokens(opts WriteOptions) []string {
+	toks := make([]string, 0, 3*len(v.Plies))
+	if v.Comment != "" {
+		toks = append(toks, "{"+v.Comment+"}")
+	}
+	number, white := int(v.MoveNumber), v.WhiteMove
+	if number == 0 {
+		number, white = 1, true
+	}
+	needNumber := true
+	for _, ply := range v.Plies {
+		if white {
+			toks = append(toks, fmt.Sprintf("%d.", number))
+		} else if needNumber {
+			toks = append(toks, fmt.Sprintf("%d...", number))
+		}
+		san, nags := ply.SAN, make([]string, 0, len(ply.Nags))
+		for _, nag := range ply.Nags {
+			if opts.GlyphAnnotations && 1 <= nag && nag <= 6 && san == ply.SAN {
+				san += nagGlyphs[nag]
+			} else {
+				nags = append(nags, fmt.Sprintf("$%d", nag))
+			}
+		}
+		toks = append(toks, san)
+		toks = append(toks, nags...)
+		if comment := ply.exportComment(); comment != "" {
+			toks = append(toks, "{"+comment+"}")
+		}
+		for i := range ply.Variations {
+			rav := ply.Variations[i].movetextTokens(opts)
+			if len(rav) == 0 {
+				toks = append(toks, "()")
+				continue
+			}
+			rav[0] = "(" + rav[0]
+			rav[len(rav)-1] += ")"
+			toks = append(toks, rav...)
+		}
+		needNumber = ply.exportComment() != "" || len(ply.Variations) > 0
+		if !white {
+			number++
+		}
+		white = !white
+	}
+	return toks
+}
+
+// exportComment returns the comment of the ply with the
+// commands parsed from it written back
+func (ply *Ply) exportComment() string {
+	comment := ply.Comment
+	keys := make([]string, 0, len(ply.Commands))
+	for key := range ply.Commands {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i := len(keys) - 1; i >= 0; i-- {
+		comment = "[%" + keys[i] + " " + ply.Commands[keys[i]] + "]" + comment
+	}
+	if len(ply.Arrows) > 0 {
+		items := make([]string, len(ply.Arrows))
+		for i, a := range ply.Arrows {
+			items[i] = a.Color + a.From + a.To
+		}
+		comment = "[%cal " + strings.Join(items, ",") + "]" + comment
+	}
+	if len(ply.Highlights) > 0 {
+		items := make([]string, len(ply.Highlights))
+		for i, h := range ply.Highlights {
+			items[i] = h.Color + h.Square
+		}
+		comment = "[%csl " + strings.Join(items, ",") + "]" + comment
+	}
+	if ply.Elapsed != 0 {
+		comment = "[%emt " + formatClockTime(ply.Elapsed) + "]" + comment
+	}
+	if ply.Clock != 0 {
+		comment = "[%clk " + formatClockTime(ply.Clock) + "]" + comment
+	}
+	if ply.Eval != nil {
+		comment = "[%eval " + ply.Eval.String() + "]" + comment
+	}
+	return comment
+}
+
+// String returns the evaluation as in the [%eval] command
+func (e *Evaluation) String() string {
+	s := strconv.FormatFloat(e.Pawns, 'f', -1, 64)
+	if e.Mate != 0 {
+		s = "#" + strconv.Itoa(e.Mate)
+	}
+	if e.Depth != 0 {
+		s += "," + strconv.Itoa(e.Depth)
+	}
+	return s
+}
+
+// formatClockTime is the inverse of parseClockTime
+func formatClockTime(d time.Duration) string {
+	h, m := d/time.Hour, (d%time.Hour)/time.Minute
+	s := float64(d%time.Minute) / float64(time.Second)
+	if s == float64(int(s)) {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, int(s))
+	}
+	return fmt.Sprintf("%d:%02d:%04.1f", h, m, s)
+}
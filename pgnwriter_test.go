@@ -0,0 +1,38 @@
+package gochess
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePGNGlyphs(t *testing.T) {
+	game := mustParseGame(t, "[Event \"x\"]\n[Result \"*\"]\n\n1. e4 $3 e5 $2 2. Nf3 $14 *\n")
+	var buf bytes.Buffer
+	if err := game.WritePGN(&buf, WriteOptions{GlyphAnnotations: true}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "1. e4!! e5? 2. Nf3 $14 *") {
+		t.Errorf("WritePGN with glyphs:\n%s", s)
+	}
+	buf.Reset()
+	if err := game.WritePGN(&buf, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); !strings.Contains(s, "1. e4 $3 e5 $2 2. Nf3 $14 *") {
+		t.Errorf("WritePGN without glyphs:\n%s", s)
+	}
+}
+
+func TestVariationMoveText(t *testing.T) {
+	game := mustParseMoves(t, "1. e4 e5 2. Nf3 (2. Bc4 Nf6) Nc6 *")
+	if want := "1. e4 e5 2. Nf3 (2. Bc4 Nf6) 2... Nc6"; game.Moves.MoveText() != want {
+		t.Errorf("MoveText() = %q, want %q", game.Moves.MoveText(), want)
+	}
+	if rav := game.Moves.Plies[2].Variations[0].MoveText(); rav != "2. Bc4 Nf6" {
+		t.Errorf("MoveText() of the variation = %q, want %q", rav, "2. Bc4 Nf6")
+	}
+	if text := new(Variation).MoveText(); text != "" {
+		t.Errorf("MoveText() of an empty variation = %q", text)
+	}
+}
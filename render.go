@@ -0,0 +1,110 @@
+package gochess
+
+import (
+	"strings"
+)
+
+var (
+	unicodePieces [2][7]string = [2][7]string{
+		{".", "♙", "♘", "♗", "♖", "♕", "♔"},
+		{".", "♟", "♞", "♝", "♜", "♛", "♚"},
+	}
+)
+
+// RenderOptions controls how Render prints a board
+type RenderOptions struct {
+	// Unicode if true prints pieces as unicode chess symbols
+	// else as the FEN letters
+	Unicode bool
+	// Flip if true prints the board from black's side, with a1 at the top right
+	Flip bool
+	// Coordinates if true prints the rank and file labels
+	Coordinates bool
+}
+
+// Render returns a text diagram of the board, one rank per line.
+// Empty squares are printed as dots
+func (b *Board) Render(opts RenderOptions) string {
+	var buf strings.Builder
+	files := "abcdefgh"
+	if opts.Flip {
+		files = "hgfedcba"
+	}
+	for r := 0; r < 8; r++ {
+		rank := 8 - r
+		if opts.Flip {
+			rank = r + 1
+		}
+		if opts.Coordinates {
+			buf.WriteString(string(rune('0'+rank)) + " ")
+		}
+		for f := 0; f < 8; f++ {
+			if f > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(b.renderSquare(string2sq(files[f:f+1]+string(rune('0'+rank))), opts))
+		}
+		buf.WriteByte('\n')
+	}
+	if opts.Coordinates {
+		buf.WriteString("  " + strings.Join(strings.Split(files, ""), " ") + "\n")
+	}
+	return buf.String()
+}
+
+func (b *Board) renderSquare(sq int8, opts RenderOptions) string {
+	piece := b.sq[sq]
+	if piece == 0 {
+		return "."
+	}
+	if opts.Unicode {
+		col, typ := piece.identify()
+		return unicodePieces[col][typ]
+	}
+	return piece.String()
+}
+
+// RenderHighlight returns a text diagram of the board with coordinates,
+// where the pieces on the given squares are enclosed in brackets.
+// It is useful to show the last move or legal move hints in a terminal.
+// Invalid square names are ignored
+func (b *Board) RenderHighlight(squares []string) string {
+	marked := make(map[int8]bool)
+	for _, s := range squares {
+		if sq, err := parseSquare(s); err == nil {
+			marked[sq] = true
+		}
+	}
+	var buf strings.Builder
+	for rank := '8'; rank >= '1'; rank-- {
+		buf.WriteString(string(rank) + " ")
+		for file := 'a'; file <= 'h'; file++ {
+			sq := string2sq(string(file) + string(rank))
+			if marked[sq] {
+				buf.WriteString("[" + b.renderSquare(sq, RenderOptions{}) + "]")
+			} else {
+				buf.WriteString(" " + b.renderSquare(sq, RenderOptions{}) + " ")
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("   a  b  c  d  e  f  g  h\n")
+	return buf.String()
+}
+
+// Flipped returns a copy of the board rotated by 180 degrees, so that
+// h1 is at the top left as black sees it. The pieces keep their colors
+// and the side to move does not change. It is meant only for printing
+// the board from black's side, the position is not a real one
+func (b *Board) Flipped() *Board {
+	f := b.clone()
+	for sq := range b.sq {
+		f.sq[sq] = b.sq[len(b.sq)-1-sq]
+	}
+	f.wksq = int8(len(b.sq)-1) - b.wksq
+	f.bksq = int8(len(b.sq)-1) - b.bksq
+	if b.epsq != 0 {
+		f.epsq = int8(len(b.sq)-1) - b.epsq
+	}
+	return f
+}
@@ -0,0 +1,69 @@
+package gochess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	b := NewBoard()
+	lines := strings.Split(b.Render(RenderOptions{Flip: true}), "\n")
+	if lines[0] != "R N B K Q B N R" || lines[7] != "r n b k q b n r" {
+		t.Errorf("flipped diagram starts %q and ends %q", lines[0], lines[7])
+	}
+	if b.MakeMove("e4") != nil {
+		t.Fatal("e4 failed")
+	}
+	flipped := b.Render(RenderOptions{Flip: true, Coordinates: true})
+	if lines := strings.Split(flipped, "\n"); lines[0] != "1 R N B K Q B N R" || lines[3] != "4 . . . P . . . ." || lines[8] != "  h g f e d c b a" {
+		t.Errorf("flipped diagram with coordinates:\n%s", flipped)
+	}
+	plain := b.Render(RenderOptions{})
+	if strings.ContainsAny(plain, "12345678") {
+		t.Errorf("diagram without coordinates has labels:\n%s", plain)
+	}
+	if lines := strings.Split(plain, "\n"); len(lines) != 9 || lines[4] != ". . . . P . . ." {
+		t.Errorf("diagram:\n%s", plain)
+	}
+	if u := b.Render(RenderOptions{Unicode: true}); !strings.HasPrefix(u, "♜ ♞ ♝ ♛ ♚ ♝ ♞ ♜\n") {
+		t.Errorf("unicode diagram:\n%s", u)
+	}
+}
+
+func TestRenderHighlight(t *testing.T) {
+	b := NewBoard()
+	if err := b.MakeMove("e4"); err != nil {
+		t.Fatal(err)
+	}
+	s := b.RenderHighlight([]string{"e2", "e4", "z9"})
+	lines := strings.Split(s, "\n")
+	if want := "4  .  .  .  . [P] .  .  . "; lines[4] != want {
+		t.Errorf("rank 4 = %q, want %q", lines[4], want)
+	}
+	if want := "2  P  P  P  P [.] P  P  P "; lines[6] != want {
+		t.Errorf("rank 2 = %q, want %q", lines[6], want)
+	}
+	if n := strings.Count(s, "["); n != 2 {
+		t.Errorf("%d squares are marked, want 2:\n%s", n, s)
+	}
+}
+
+func TestFlipped(t *testing.T) {
+	b, err := NewBoardFromMoves("e4", "Nf6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := b.Flipped()
+	if s, want := f.Render(RenderOptions{}), b.Render(RenderOptions{Flip: true}); s != want {
+		t.Errorf("Flipped() diagram:\n%s\nwant:\n%s", s, want)
+	}
+	if s := f.Render(RenderOptions{}); !strings.HasPrefix(s, "R N B K Q B N R\n") {
+		t.Errorf("top left of the flipped board is not h1:\n%s", s)
+	}
+	if f.KingSquare(true) != "d8" || f.KingSquare(false) != "d1" {
+		t.Errorf("kings of the flipped board on %s and %s, want d8 and d1", f.KingSquare(true), f.KingSquare(false))
+	}
+	if b.Fen() != "rnbqkb1r/pppppppp/5n2/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 1 2" {
+		t.Errorf("Flipped() changed the board: %s", b.Fen())
+	}
+}